@@ -9,7 +9,10 @@ import (
 
 var CLI struct {
 	cmd.GlobalOptions
-	Run *cmd.RunOptions `help:"Run a workflow" cmd:""`
+	Run      *cmd.RunOptions      `help:"Run a workflow" cmd:""`
+	Convert  *cmd.ConvertOptions  `help:"Convert a workflow or task definition between JSON, TOML, and YAML" cmd:""`
+	Validate *cmd.ValidateOptions `help:"Check a workflow for problems, reporting all of them" cmd:""`
+	Schema   *cmd.SchemaOptions   `help:"Print the JSON Schema for workflow files" cmd:""`
 }
 
 func main() {
@@ -28,6 +31,21 @@ func main() {
 		if err != nil {
 			ctx.FatalIfErrorf(err)
 		}
+	case "validate <workflow>":
+		err := cmd.Validate(CLI.Validate, CLI.GlobalOptions)
+		if err != nil {
+			ctx.FatalIfErrorf(err)
+		}
+	case "schema":
+		err := cmd.Schema(CLI.Schema, CLI.GlobalOptions)
+		if err != nil {
+			ctx.FatalIfErrorf(err)
+		}
+	case "convert <input>":
+		err := cmd.Convert(CLI.Convert, CLI.GlobalOptions)
+		if err != nil {
+			ctx.FatalIfErrorf(err)
+		}
 	}
 }
 
@@ -6,9 +6,15 @@ require github.com/google/uuid v1.3.0
 
 require (
 	cloud.google.com/go/storage v1.33.0
+	github.com/BurntSushi/toml v1.3.2
 	github.com/alecthomas/assert/v2 v2.1.0
 	github.com/alecthomas/kong v0.7.1
+	github.com/bmatcuk/doublestar/v4 v4.10.2
+	github.com/fullstorydev/emulators/storage v0.0.0-20230523204811-eccb7d2267b0
+	golang.org/x/crypto v0.11.0
 	golang.org/x/sync v0.3.0
+	google.golang.org/api v0.132.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -18,7 +24,6 @@ require (
 	cloud.google.com/go/iam v1.1.0 // indirect
 	github.com/alecthomas/repr v0.1.0 // indirect
 	github.com/bluele/gcache v0.0.2 // indirect
-	github.com/fullstorydev/emulators/storage v0.0.0-20230523204811-eccb7d2267b0 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/google/btree v1.0.1 // indirect
@@ -28,13 +33,11 @@ require (
 	github.com/googleapis/gax-go/v2 v2.12.0 // indirect
 	github.com/hexops/gotextdiff v1.0.3 // indirect
 	go.opencensus.io v0.24.0 // indirect
-	golang.org/x/crypto v0.11.0 // indirect
 	golang.org/x/net v0.12.0 // indirect
 	golang.org/x/oauth2 v0.10.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	google.golang.org/api v0.132.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20230706204954-ccb25ca9f130 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20230706204954-ccb25ca9f130 // indirect
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+type ConvertOptions struct {
+	Input  string `help:"Path or URL of the workflow (or task) definition to convert" arg:""`
+	To     string `help:"Format to convert to (json, toml, yaml), defaults to the format implied by --output"`
+	Output string `help:"File to write the converted definition to, defaults to stdout" short:"o"`
+	Task   bool   `help:"Treat the input as a task definition rather than a workflow"`
+}
+
+func (o *ConvertOptions) format() (spec.Format, error) {
+	if o.To != "" {
+		return spec.ParseFormat(o.To)
+	}
+
+	if o.Output != "" {
+		f, err := spec.ParseFormat(filepath.Ext(o.Output))
+		if err == nil {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("no output format given, use --to or an --output with a known extension")
+}
+
+func Convert(convert *ConvertOptions, global GlobalOptions) error {
+	f, err := convert.format()
+	if err != nil {
+		return err
+	}
+
+	store, err := dataStore(global.StoreOptions)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	loader := spec.NewLoader(store)
+
+	// Task references are resolved as the definition is loaded, so
+	// the converted definition is self-contained.
+	var def any
+	if convert.Task {
+		def, err = loader.Task(files.Path(convert.Input))
+	} else {
+		def, err = loader.Workflow(files.Path(convert.Input))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load definition (%s): %w", convert.Input, err)
+	}
+
+	var out io.Writer = os.Stdout
+	if convert.Output != "" {
+		file, err := os.Create(convert.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file (%s): %w", convert.Output, err)
+		}
+		defer func() { _ = file.Close() }()
+
+		out = file
+	}
+
+	err = spec.Encode(out, def, f)
+	if err != nil {
+		return fmt.Errorf("failed to write definition as %s: %w", f, err)
+	}
+
+	return nil
+}
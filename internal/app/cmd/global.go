@@ -3,4 +3,6 @@ package cmd
 type GlobalOptions struct {
 	Debug   bool `help:"Enable developer debugging output"`
 	Verbose bool `help:"Enable verbose user output"`
+
+	StoreOptions
 }
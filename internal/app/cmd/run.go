@@ -1,30 +1,56 @@
 package cmd
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/glesica/flowork/internal/app/options"
 	"github.com/glesica/flowork/internal/pkg/files"
 	"github.com/glesica/flowork/internal/pkg/id"
 	"github.com/glesica/flowork/internal/pkg/inputs"
+	"github.com/glesica/flowork/internal/pkg/locality"
+	"github.com/glesica/flowork/internal/pkg/option"
 	"github.com/glesica/flowork/internal/pkg/spec"
 	"github.com/glesica/flowork/internal/pkg/task"
 	"github.com/glesica/flowork/internal/pkg/workflow"
 )
 
 type RunOptions struct {
-	Name        string    `help:"A human-readable name for this workflow run, will be used as a directory name"`
-	Workflow    string    `help:"Path to workflow definition to execute" arg:""`
-	Runner      string    `help:"Task runner to use" enum:"docker" default:"docker"`
-	WorkDir     files.Dir `help:"Local working directory to use" default:"."`
-	Input       files.Dir `help:"A directory to load inputs from"`
-	Output      files.Dir `help:"A directory to save the outputs"`
-	Concurrency int64     `help:"Max number of concurrent jobs (<1 means unlimited)" default:"1"`
+	Name          string            `help:"A human-readable name for this workflow run, will be used as a directory name"`
+	Workflow      string            `help:"Path or URL of the workflow definition to execute" arg:""`
+	Runner        string            `help:"Task runner to use" enum:"docker,docker-api,podman,nerdctl,local" default:"docker"`
+	IgnoreImage   bool              `help:"Let the local runner run tasks outside of their images"`
+	WorkDir       files.Dir         `help:"Local working directory to use" default:"."`
+	Input         files.Dir         `help:"A directory to load inputs from, local or a URL like gs://bucket/inputs"`
+	Manifest      string            `help:"Path or URL of a manifest to load inputs from instead, a list of paths or a CSV, TSV, or JSONL file whose other columns are available to templates"`
+	Column        string            `name:"manifest-column" help:"Column, or field, of the manifest that holds the input paths" default:"path"`
+	Recursive     bool              `help:"Look for inputs in subdirectories of the input directory"`
+	MaxDepth      int               `help:"Max number of directory levels to look for inputs in, with --recursive (0 means no limit)" default:"0"`
+	Glob          string            `help:"Only use inputs whose paths, relative to the input directory, match the pattern, like **/*.fastq.gz"`
+	Hidden        bool              `help:"Use hidden input files, and look in hidden directories"`
+	Symlinks      string            `help:"Which symlinks to follow when looking for inputs (skip, files, follow)" enum:"skip,files,follow" default:"files"`
+	Watch         bool              `help:"Keep running, and process new files as they appear in --input, until interrupted"`
+	WatchInterval time.Duration     `help:"How often to check for new inputs, with --watch" default:"10s"`
+	WatchState    string            `help:"File used to remember which inputs have been processed, with --watch, so they are skipped after a restart (defaults to a file next to the run directory, named for the workflow and --input)"`
+	Include       []string          `help:"Only use inputs whose names match the glob, may be repeated" sep:"none"`
+	Exclude       []string          `help:"Skip inputs whose names match the glob, may be repeated" sep:"none"`
+	Output        files.Dir         `help:"A directory to save the outputs, and the captured output of tasks, local or a URL like gs://bucket/run"`
+	Concurrency   int64             `help:"Max number of concurrent jobs (<1 means unlimited)" default:"1"`
+	Retries       int               `help:"Number of times to retry a failed job, resuming at the failed task" default:"0"`
+	Echo          bool              `help:"Print task output to the terminal, as it is produced, prefixed with [task/job]"`
+	Transfers     string            `help:"What to do when data must move between environments (allow, warn, deny)" enum:"allow,warn,deny" default:"warn"`
+	Params        map[string]string `name:"param" help:"Set a workflow parameter, as key=value, may be repeated" mapsep:"none"`
+	VolumePool    int               `help:"Max number of cleared volumes to keep for reuse by container runners (0 disables)" default:"0"`
 }
 
 func (o *RunOptions) setName() error {
@@ -68,8 +94,15 @@ func (o *RunOptions) setWorkDir() error {
 func (o *RunOptions) setOutput() error {
 	if o.Output == "" {
 		o.Output = o.WorkDir.SubDir(options.OutputsDirName)
+		return nil
 	}
 
+	out, err := absPath(string(o.Output))
+	if err != nil {
+		return err
+	}
+	o.Output = files.Dir(out)
+
 	return nil
 }
 
@@ -106,34 +139,312 @@ func Run(run *RunOptions, global GlobalOptions) error {
 		return fmt.Errorf("failed to set concurrency: %w", err)
 	}
 
-	ws, err := spec.LoadWorkflowPath(run.Workflow)
+	store, err := dataStore(global.StoreOptions)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	// Definitions can live anywhere that inputs can, and so can the
+	// tasks they reference.
+	ws, err := spec.NewLoader(store).Workflow(files.Path(run.Workflow))
 	if err != nil {
 		return fmt.Errorf("failed to load workflow (%s): %w", run.Workflow, err)
 	}
 
-	wi := workflow.NewInstance(ws)
+	// Filters given on the command line extend those declared by the
+	// workflow.
+	for _, g := range run.Include {
+		ws.Include = append(ws.Include, spec.Filter{Glob: g})
+	}
+	for _, g := range run.Exclude {
+		ws.Exclude = append(ws.Exclude, spec.Filter{Glob: g})
+	}
+
+	filter, err := inputs.WithSpec(ws.Include, ws.Exclude)
+	if err != nil {
+		return fmt.Errorf("invalid input filters: %w", err)
+	}
+
+	if !store.Accepts(run.Output.PathTo("output")) {
+		return fmt.Errorf("unsupported output location (%s)", run.Output)
+	}
+
+	var manifest *inputs.Manifest
+	var vOpts []option.Func[*spec.Validator]
+	if run.Manifest != "" {
+		if run.Recursive || run.Glob != "" || run.Hidden || run.Symlinks != "files" {
+			return fmt.Errorf("--manifest can't be used with --recursive, --glob, --hidden, or --symlinks")
+		}
+
+		p, err := absPath(run.Manifest)
+		if err != nil {
+			return err
+		}
+
+		mOpts := []option.Func[*inputs.Manifest]{inputs.WithColumn(run.Column)}
+		if len(ws.Include) > 0 || len(ws.Exclude) > 0 {
+			// Only look up the inputs when there are filters to
+			// apply, since it means a request for each of them.
+			mOpts = append(mOpts, inputs.WithManifestFilters(filter))
+		}
+
+		manifest, err = inputs.NewManifest(store, p, mOpts...)
+		if err != nil {
+			return err
+		}
+
+		vOpts = append(vOpts, spec.WithMetaColumns(manifest.Columns()))
+	}
+
+	v, err := validator(run.Runner, run.Params, vOpts...)
+	if err != nil {
+		return err
+	}
+
+	diags := v.Workflow(ws)
+	for _, d := range diags {
+		if d.Severity == spec.SeverityWarning {
+			slog.Warn("workflow may have a problem", "problem", d.String())
+		}
+	}
+
+	err = diags.Err()
+	if err != nil {
+		printDiagnostics(os.Stderr, diags.Errors())
+		return fmt.Errorf("invalid workflow (%s), see flowork validate", run.Workflow)
+	}
+
+	params, err := ws.ParamValues(run.Params)
+	if err != nil {
+		return fmt.Errorf("invalid workflow params: %w", err)
+	}
+
+	in, done, err := runInputs(run, filter, manifest, store)
+	if err != nil {
+		return fmt.Errorf("failed to load inputs: %w", err)
+	}
+
+	wiOpts := []option.Func[*workflow.Instance]{
+		// Captured task output goes with the outputs, in whatever
+		// store they are in.
+		workflow.WithCaptureDir(run.Output.SubDir(options.CapturesDirName)),
+		workflow.WithMaxRetries(run.Retries),
+		workflow.WithParams(params),
+		workflow.WithRunName(run.Name),
+		workflow.WithCaptureStore(store),
+	}
+	if run.Echo {
+		wiOpts = append(wiOpts, workflow.WithEcho(os.Stdout, os.Stderr))
+	}
+	if manifest != nil {
+		wiOpts = append(wiOpts, workflow.WithInputMeta(manifest.Meta))
+	}
+	if done != nil {
+		wiOpts = append(wiOpts, workflow.WithOnSuccess(done))
+	}
+
+	wi, err := workflow.NewInstance(ws, wiOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to create workflow instance: %w", err)
+	}
 
 	var runner task.Runner
 	switch run.Runner {
-	case "docker":
-		runner = &task.DockerRunner{
+	case "docker", "podman", "nerdctl":
+		rt, err := task.RuntimeFor(run.Runner)
+		if err != nil {
+			return err
+		}
+
+		dr := &task.DockerRunner{
+			Debug:    global.Debug,
+			WorkDir:  run.WorkDir,
+			Store:    store,
+			Runtime:  rt,
+			PoolSize: run.VolumePool,
+		}
+		defer func() {
+			err := dr.Close()
+			if err != nil {
+				slog.Warn("failed to delete pooled volumes", "error", err)
+			}
+		}()
+
+		runner = dr
+	case "docker-api":
+		runner = &task.DockerApiRunner{
 			Debug:   global.Debug,
 			WorkDir: run.WorkDir,
-			Store:   &files.Local{},
+			Store:   store,
+		}
+	case "local":
+		runner = &task.LocalRunner{
+			Debug:       global.Debug,
+			IgnoreImage: run.IgnoreImage,
+			WorkDir:     run.WorkDir,
+			Store:       store,
 		}
 	default:
 		return fmt.Errorf("invalid runner (%s)", run.Runner)
 	}
 
-	in, err := inputs.Local(run.Input)
+	policy, err := locality.ParsePolicy(run.Transfers)
 	if err != nil {
-		return fmt.Errorf("failed to load inputs: %w", err)
+		return err
 	}
 
-	err = workflow.Run(wi, runner, in, run.Output, run.Concurrency)
+	runner, err = locality.NewRunner(runner, store, locality.WithPolicy(policy))
+	if err != nil {
+		return fmt.Errorf("failed to apply transfer policy: %w", err)
+	}
+
+	ctx := context.Background()
+	if run.Watch {
+		// Stop watching for new inputs on the first interrupt, and let
+		// the jobs that are running finish. A second interrupt exits
+		// right away, as usual.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		go func() {
+			select {
+			case <-signals:
+				signal.Stop(signals)
+				cancel()
+				slog.Info("stopped watching for inputs, waiting for running jobs to finish")
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	err = workflow.Run(ctx, wi, runner, in, run.Output, run.Concurrency)
 	if err != nil {
 		return fmt.Errorf("failed to run workflow (%s): %w", run.Workflow, err)
 	}
 
 	return nil
 }
+
+// runInputs returns an iterator over the inputs for the run, which
+// come from the manifest, if there is one, or the input directory.
+// When watching, it also returns a function to call with each input
+// whose job succeeds, so that it is remembered. The manifest has
+// already been filtered when it was loaded.
+func runInputs(run *RunOptions, filter inputs.Filter, manifest *inputs.Manifest, store *files.Multi) (inputs.Iterator, func(files.Path) error, error) {
+	if manifest != nil {
+		if run.Watch {
+			return nil, nil, fmt.Errorf("--watch can't be used with --manifest")
+		}
+		return manifest.Iterate, nil, nil
+	}
+
+	if run.Watch {
+		w, err := watchInputs(run, filter, store)
+		if err != nil {
+			return nil, nil, err
+		}
+		return w.Iterate, w.Done, nil
+	}
+
+	dir, err := absPath(string(run.Input))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !(&files.Local{}).Accepts(dir) {
+		// Other stores can only list a single directory.
+		if run.Recursive || run.Glob != "" {
+			return nil, nil, fmt.Errorf("--recursive and --glob can only be used with local inputs")
+		}
+
+		filters := []inputs.Filter{filter}
+		if !run.Hidden {
+			filters = append(filters, notHidden)
+		}
+
+		in, err := inputs.List(store, files.Dir(dir), filters...)
+		return in, nil, err
+	}
+
+	symlinks, err := inputs.ParseSymlinkPolicy(run.Symlinks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	walkOpts := []option.Func[*inputs.Walker]{
+		inputs.WithFilters(filter),
+		inputs.WithSymlinks(symlinks),
+	}
+	if run.Recursive {
+		walkOpts = append(walkOpts, inputs.WithMaxDepth(run.MaxDepth))
+	}
+	if run.Glob != "" {
+		walkOpts = append(walkOpts, inputs.WithPattern(run.Glob))
+	}
+	if run.Hidden {
+		walkOpts = append(walkOpts, inputs.WithHidden())
+	}
+
+	in, err := inputs.Walk(files.Dir(dir), walkOpts...)
+	return in, nil, err
+}
+
+// notHidden skips hidden files, whose names start with a dot.
+func notHidden(i files.Info) bool {
+	return !strings.HasPrefix(i.Path.File(), ".")
+}
+
+// watchInputs returns a watcher for the new files that appear in the
+// input directory, which may be in any store that can list files.
+func watchInputs(run *RunOptions, filter inputs.Filter, store files.Lister) (*inputs.Watcher, error) {
+	if run.Recursive || run.Glob != "" {
+		return nil, fmt.Errorf("--watch can't be used with --recursive or --glob")
+	}
+
+	dir, err := absPath(string(run.Input))
+	if err != nil {
+		return nil, err
+	}
+
+	state := run.WatchState
+	if state == "" {
+		state, err = defaultWatchState(run, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []option.Func[*inputs.Watcher]{
+		inputs.WithPollInterval(run.WatchInterval),
+		inputs.WithStateFile(state),
+		inputs.WithWatchFilters(filter),
+	}
+	if run.Hidden {
+		opts = append(opts, inputs.WithWatchHidden())
+	}
+
+	return inputs.NewWatcher(store, files.Dir(dir), opts...)
+}
+
+// defaultWatchState returns the state file used to watch the given
+// directory for the workflow. The work directory includes the run
+// name, which changes from run to run, so the file goes in the
+// directory it is in. The name includes a hash of the workflow and
+// the directory, so different watches don't share a file.
+func defaultWatchState(run *RunOptions, dir files.Path) (string, error) {
+	wf, err := absPath(run.Workflow)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(string(wf) + "\n" + string(dir)))
+	name := fmt.Sprintf("flowork-watch-%s.txt", hex.EncodeToString(sum[:])[:16])
+
+	return filepath.Join(filepath.Dir(string(run.WorkDir)), name), nil
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+type SchemaOptions struct {
+	Output string `help:"File to write the schema to, defaults to stdout" short:"o"`
+}
+
+func Schema(schema *SchemaOptions, global GlobalOptions) error {
+	if schema.Output == "" {
+		_, err := os.Stdout.Write(spec.Schema())
+		return err
+	}
+
+	err := os.WriteFile(schema.Output, spec.Schema(), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write schema (%s): %w", schema.Output, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// StoreOptions configure access to the places data can be stored.
+// GCS uses the application default credentials, which can be set with
+// GOOGLE_APPLICATION_CREDENTIALS, or the emulator named by
+// STORAGE_EMULATOR_HOST. S3 takes its credentials from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN.
+type StoreOptions struct {
+	HttpHeader  map[string]string `help:"Send a header with every HTTP(S) request, as key=value, may be repeated" mapsep:"none"`
+	HttpToken   string            `help:"Bearer token to send with every HTTP(S) request" env:"FLOWORK_HTTP_TOKEN"`
+	HttpRetries int               `help:"Number of times to retry a failed HTTP(S) request" default:"2"`
+	S3Region    string            `name:"s3-region" help:"Region of the S3 buckets (defaults to AWS_REGION, or us-east-1)"`
+	S3Endpoint  string            `name:"s3-endpoint" help:"URL of an S3-compatible service to use instead of AWS, like MinIO (defaults to AWS_ENDPOINT_URL_S3)"`
+}
+
+// dataStore returns the store used for everything a command reads and
+// writes: workflow and task definitions, inputs, including manifests,
+// outputs, and captured data. It handles local paths, HTTP(S) URLs,
+// gs:// URLs, and s3:// URLs. Commands create one, and close it when
+// they are done.
+func dataStore(o StoreOptions) (*files.Multi, error) {
+	httpOpts := []option.Func[*files.Http]{
+		files.WithRetries(o.HttpRetries, time.Second),
+	}
+	for k, v := range o.HttpHeader {
+		httpOpts = append(httpOpts, files.WithHeader(k, v))
+	}
+	if o.HttpToken != "" {
+		httpOpts = append(httpOpts, files.WithBearerToken(o.HttpToken))
+	}
+
+	h, err := files.NewHttp(httpOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP store: %w", err)
+	}
+
+	// The GCS client is only created when it is first used, so runs
+	// that don't touch GCS don't need credentials.
+	g, err := files.NewGcs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS store: %w", err)
+	}
+
+	s3, err := files.NewS3(
+		files.WithS3Region(o.S3Region),
+		files.WithS3Endpoint(o.S3Endpoint),
+		files.WithS3Http(files.WithRetries(o.HttpRetries, time.Second)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 store: %w", err)
+	}
+
+	return files.NewMulti(
+		files.WithStore(&files.Local{}),
+		files.WithStore(h),
+		files.WithStore(g),
+		files.WithStore(s3),
+	)
+}
+
+// absPath makes a local path absolute, since that is what files.Local
+// expects. Paths with a scheme, like URLs, are left alone.
+func absPath(p string) (files.Path, error) {
+	u, err := url.Parse(p)
+	if err == nil && u.Scheme != "" {
+		return files.Path(p), nil
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path (%s): %w", p, err)
+	}
+
+	return files.Path(abs), nil
+}
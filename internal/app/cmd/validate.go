@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/inputs"
+	"github.com/glesica/flowork/internal/pkg/option"
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+type ValidateOptions struct {
+	Workflow string            `help:"Path or URL of the workflow definition to validate" arg:""`
+	Runner   string            `help:"Task runner the workflow will be run with" enum:"docker,docker-api,podman,nerdctl,local" default:"docker"`
+	Params   map[string]string `name:"param" help:"Set a workflow parameter, as key=value, may be repeated" mapsep:"none"`
+	Manifest string            `help:"Path or URL of the manifest the workflow will be run with, so templates can use its columns"`
+	Column   string            `name:"manifest-column" help:"Column, or field, of the manifest that holds the input paths" default:"path"`
+}
+
+// validator returns a validator suited to the given runner and
+// parameter values, with any extra options.
+func validator(runner string, params map[string]string, extra ...option.Func[*spec.Validator]) (*spec.Validator, error) {
+	opts := []option.Func[*spec.Validator]{
+		spec.WithParams(params),
+	}
+	opts = append(opts, extra...)
+	if runner == "local" {
+		opts = append(opts, spec.WithoutImages())
+	}
+
+	return spec.NewValidator(opts...)
+}
+
+// printDiagnostics writes one diagnostic per line.
+func printDiagnostics(w io.Writer, diags spec.Diagnostics) {
+	for _, d := range diags {
+		_, _ = fmt.Fprintln(w, d.String())
+	}
+}
+
+func Validate(validate *ValidateOptions, global GlobalOptions) error {
+	store, err := dataStore(global.StoreOptions)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	ws, err := spec.NewLoader(store).Workflow(files.Path(validate.Workflow))
+	if err != nil {
+		return fmt.Errorf("failed to load workflow (%s): %w", validate.Workflow, err)
+	}
+
+	var vOpts []option.Func[*spec.Validator]
+	if validate.Manifest != "" {
+		p, err := absPath(validate.Manifest)
+		if err != nil {
+			return err
+		}
+
+		manifest, err := inputs.NewManifest(store, p, inputs.WithColumn(validate.Column))
+		if err != nil {
+			return err
+		}
+
+		vOpts = append(vOpts, spec.WithMetaColumns(manifest.Columns()))
+	}
+
+	v, err := validator(validate.Runner, validate.Params, vOpts...)
+	if err != nil {
+		return err
+	}
+
+	diags := v.Workflow(ws)
+	printDiagnostics(os.Stdout, diags)
+
+	if errs := diags.Errors(); len(errs) > 0 {
+		return fmt.Errorf("workflow (%s) has %d error(s)", validate.Workflow, len(errs))
+	}
+
+	return nil
+}
@@ -5,3 +5,5 @@ const DefaultTaskWorkDir = "/work"
 const VolumesDirName = "volumes"
 
 const OutputsDirName = "outputs"
+
+const CapturesDirName = "captures"
@@ -0,0 +1,202 @@
+package files
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Checksums holds checksums of the contents of a file, as lowercase
+// hex. A checksum that isn't known is empty.
+type Checksums struct {
+	SHA256 string `json:"sha256,omitempty"`
+	MD5    string `json:"md5,omitempty"`
+	CRC32C string `json:"crc32c,omitempty"`
+}
+
+// A Checksummer is a Store that can report checksums of a file, as
+// recorded by the storage service, without reading its contents. The
+// checksums it doesn't know about are left empty.
+type Checksummer interface {
+	Checksums(p Path) (Checksums, error)
+}
+
+// ChecksumsOf returns the checksums of the file at the given path if
+// the store supports it, and no checksums otherwise.
+func ChecksumsOf(s Store, p Path) (Checksums, error) {
+	checksummer, ok := s.(Checksummer)
+	if !ok {
+		return Checksums{}, nil
+	}
+
+	return checksummer.Checksums(p)
+}
+
+// ChecksumError is returned when the checksum of the data that was
+// copied doesn't match the checksum reported for the file by a store.
+type ChecksumError struct {
+	Path      Path
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch for %s: expected %s, got %s", e.Algorithm, e.Path, e.Expected, e.Actual)
+}
+
+// Verify compares the checksums reported for the file at the given
+// path with these, which are assumed to be correct. Only checksums
+// known to both are compared.
+func (c Checksums) Verify(p Path, reported Checksums) error {
+	for _, pair := range []struct {
+		algorithm        string
+		expected, actual string
+	}{
+		{"SHA-256", c.SHA256, reported.SHA256},
+		{"MD5", c.MD5, reported.MD5},
+		{"CRC32C", c.CRC32C, reported.CRC32C},
+	} {
+		if pair.expected == "" || pair.actual == "" || pair.expected == pair.actual {
+			continue
+		}
+
+		return &ChecksumError{
+			Path:      p,
+			Algorithm: pair.algorithm,
+			Expected:  pair.expected,
+			Actual:    pair.actual,
+		}
+	}
+
+	return nil
+}
+
+// Copy copies a file from one store to another, and returns its size
+// and checksums, which are computed as the data is copied. If either
+// store can report checksums (see Checksummer), they are compared with
+// the computed ones, and a ChecksumError is returned if they differ.
+func Copy(dst Store, dp Path, src Store, sp Path) (Size, Checksums, error) {
+	expected, err := ChecksumsOf(src, sp)
+	if err != nil {
+		return SizeUnknown, Checksums{}, fmt.Errorf("failed to get checksums of %s: %w", sp, err)
+	}
+
+	r, err := src.Load(sp)
+	if err != nil {
+		return SizeUnknown, Checksums{}, fmt.Errorf("failed to load %s: %w", sp, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	h := newHashingReader(r)
+
+	var body io.Reader = h
+	if seeker, ok := r.(io.Seeker); ok {
+		// Stores may rewind the data to retry a failed save.
+		body = &seekingHashingReader{hashingReader: h, seeker: seeker}
+	}
+
+	err = dst.Save(dp, body)
+	if err != nil {
+		return SizeUnknown, Checksums{}, fmt.Errorf("failed to save %s: %w", dp, err)
+	}
+
+	// The store should have read everything, but make sure, since the
+	// checksums must cover the whole file.
+	_, err = io.Copy(io.Discard, h)
+	if err != nil {
+		return SizeUnknown, Checksums{}, fmt.Errorf("failed to load %s: %w", sp, err)
+	}
+
+	size, sums, err := h.sums()
+	if err != nil {
+		return SizeUnknown, Checksums{}, fmt.Errorf("failed to checksum %s: %w", sp, err)
+	}
+
+	err = sums.Verify(sp, expected)
+	if err != nil {
+		return SizeUnknown, Checksums{}, err
+	}
+
+	saved, err := ChecksumsOf(dst, dp)
+	if err != nil {
+		return SizeUnknown, Checksums{}, fmt.Errorf("failed to get checksums of %s: %w", dp, err)
+	}
+
+	err = sums.Verify(dp, saved)
+	if err != nil {
+		return SizeUnknown, Checksums{}, err
+	}
+
+	return size, sums, nil
+}
+
+// hashingReader computes checksums of the data read through it.
+type hashingReader struct {
+	r      io.Reader
+	size   int64
+	sha256 hash.Hash
+	md5    hash.Hash
+	crc32c hash.Hash32
+	w      io.Writer
+
+	// partial is set when reading didn't start at the beginning of
+	// the data, so the checksums are incomplete.
+	partial bool
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	h := &hashingReader{r: r}
+	h.reset(false)
+	return h
+}
+
+func (h *hashingReader) Read(b []byte) (int, error) {
+	n, err := h.r.Read(b)
+	h.size += int64(n)
+	_, _ = h.w.Write(b[:n])
+	return n, err
+}
+
+func (h *hashingReader) reset(partial bool) {
+	h.size = 0
+	h.sha256 = sha256.New()
+	h.md5 = md5.New()
+	h.crc32c = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	h.w = io.MultiWriter(h.sha256, h.md5, h.crc32c)
+	h.partial = partial
+}
+
+func (h *hashingReader) sums() (Size, Checksums, error) {
+	if h.partial {
+		return SizeUnknown, Checksums{}, fmt.Errorf("data was not read from the beginning")
+	}
+
+	return Size(h.size), Checksums{
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+		MD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		CRC32C: fmt.Sprintf("%08x", h.crc32c.Sum32()),
+	}, nil
+}
+
+// seekingHashingReader is a hashingReader for data that can be
+// rewound. Seeking starts the checksums over.
+type seekingHashingReader struct {
+	*hashingReader
+	seeker io.Seeker
+}
+
+func (s *seekingHashingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := s.seeker.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+
+	s.reset(pos != 0)
+
+	return pos, nil
+}
@@ -0,0 +1,93 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+var helloChecksums = Checksums{
+	SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	MD5:    "5d41402abc4b2a76b9719d911017c592",
+	CRC32C: "9a71bb4c",
+}
+
+// reportingStore is a Local store that reports the given checksums
+// for every file.
+type reportingStore struct {
+	Local
+	sums Checksums
+}
+
+func (s *reportingStore) Checksums(p Path) (Checksums, error) {
+	return s.sums, nil
+}
+
+func writeHello(t *testing.T) Path {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "hello.txt")
+	assert.NoError(t, os.WriteFile(p, []byte("hello"), 0644))
+
+	return Path(p)
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("should compute checksums", func(t *testing.T) {
+		src := writeHello(t)
+		dst := Path(filepath.Join(t.TempDir(), "out", "hello.txt"))
+
+		size, sums, err := Copy(&Local{}, dst, &Local{}, src)
+		assert.NoError(t, err)
+		assert.Equal(t, Size(5), size)
+		assert.Equal(t, helloChecksums, sums)
+
+		data, err := os.ReadFile(string(dst))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+	})
+
+	t.Run("should accept matching checksums", func(t *testing.T) {
+		src := writeHello(t)
+		dst := Path(filepath.Join(t.TempDir(), "hello.txt"))
+		store := &reportingStore{sums: Checksums{MD5: helloChecksums.MD5}}
+
+		_, _, err := Copy(store, dst, store, src)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should fail on a checksum mismatch", func(t *testing.T) {
+		src := writeHello(t)
+		dst := Path(filepath.Join(t.TempDir(), "hello.txt"))
+		store := &reportingStore{sums: Checksums{CRC32C: "00000000"}}
+
+		_, _, err := Copy(store, dst, &Local{}, src)
+		var ce *ChecksumError
+		assert.True(t, errors.As(err, &ce))
+		assert.Equal(t, "CRC32C", ce.Algorithm)
+		assert.Equal(t, dst, ce.Path)
+	})
+
+	t.Run("should checksum a save that was retried", func(t *testing.T) {
+		var received []byte
+		handler, calls := failingHandler(1, http.StatusServiceUnavailable, func(w http.ResponseWriter, r *http.Request) {
+			received, _ = io.ReadAll(r.Body)
+		})
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		src := writeHello(t)
+
+		_, sums, err := Copy(newTestHttp(t), Path(srv.URL+"/hello.txt"), &Local{}, src)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, "hello", string(received))
+		assert.Equal(t, helloChecksums, sums)
+	})
+}
@@ -0,0 +1,40 @@
+package files
+
+// Env identifies an environment in which files are stored, or in
+// which tasks are run. Moving data from one environment to another
+// generally costs time and, often, money (egress fees).
+type Env string
+
+const (
+	// EnvUnknown is used when the environment cannot be determined.
+	EnvUnknown Env = ""
+
+	// EnvLocal is the orchestrator machine, the one running Flowork.
+	EnvLocal Env = "local"
+
+	// EnvGcs is Google Cloud Storage.
+	EnvGcs Env = "gcs"
+
+	// EnvHttp is an arbitrary HTTP(S) server.
+	EnvHttp Env = "http"
+
+	// EnvS3 is Amazon S3, or a service compatible with it.
+	EnvS3 Env = "s3"
+)
+
+// A Locator is a Store that can report the environment in which
+// a given path is stored.
+type Locator interface {
+	Env(p Path) Env
+}
+
+// EnvOf returns the environment of the given path if the store
+// supports it, and EnvUnknown otherwise.
+func EnvOf(s Store, p Path) Env {
+	locator, ok := s.(Locator)
+	if !ok {
+		return EnvUnknown
+	}
+
+	return locator.Env(p)
+}
@@ -5,6 +5,8 @@ package files
 
 import (
 	"path"
+	"strings"
+	"time"
 )
 
 // Path is a reference to a file that can exist in any
@@ -18,7 +20,17 @@ type Path string
 // Path, it is abstracted for use with the Store and Runner
 // interfaces.
 func (p Path) Dir() Dir {
-	return Dir(path.Dir(string(p)))
+	prefix, rest := splitPrefix(string(p))
+	if prefix == "" {
+		return Dir(path.Dir(rest))
+	}
+
+	d := path.Dir(rest)
+	if d == "/" {
+		d = ""
+	}
+
+	return Dir(prefix + d)
 }
 
 // File returns the file name portion of the given path.
@@ -32,9 +44,37 @@ type Dir string
 
 // PathTo returns a path to the named file in this directory.
 func (d Dir) PathTo(name string) Path {
-	return Path(path.Join(string(d), name))
+	prefix, rest := splitPrefix(string(d))
+	return Path(prefix + path.Join(rest, name))
 }
 
 func (d Dir) SubDir(name string) Dir {
-	return Dir(path.Join(string(d), name))
+	prefix, rest := splitPrefix(string(d))
+	return Dir(prefix + path.Join(rest, name))
+}
+
+// splitPrefix separates the scheme and host of a URL, like
+// "gs://bucket", from the path that follows it, so that the path can
+// be manipulated without mangling the "//". Plain paths have no
+// prefix.
+func splitPrefix(s string) (prefix, rest string) {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return "", s
+	}
+
+	j := strings.Index(s[i+3:], "/")
+	if j < 0 {
+		return s, "/"
+	}
+
+	return s[:i+3+j], s[i+3+j:]
+}
+
+// Info describes a file, as far as it is known. The Size is
+// SizeUnknown, and the ModTime is zero, when they aren't.
+type Info struct {
+	Path    Path
+	Size    Size
+	ModTime time.Time
 }
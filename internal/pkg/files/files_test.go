@@ -18,6 +18,11 @@ func TestPath_Dir(t *testing.T) {
 		d := p.Dir()
 		assert.Equal(t, ".", d)
 	})
+
+	t.Run("should keep the scheme and host of a URL", func(t *testing.T) {
+		assert.Equal(t, "gs://bucket/a/b", Path("gs://bucket/a/b/c.txt").Dir())
+		assert.Equal(t, "gs://bucket", Path("gs://bucket/c.txt").Dir())
+	})
 }
 
 func TestPath_File(t *testing.T) {
@@ -40,6 +45,12 @@ func TestDir_PathTo(t *testing.T) {
 		p := d.PathTo("d.txt")
 		assert.Equal(t, "/a/b/c/d.txt", p)
 	})
+
+	t.Run("should keep the scheme and host of a URL", func(t *testing.T) {
+		assert.Equal(t, "gs://bucket/run/d.txt", Dir("gs://bucket/run/").PathTo("d.txt"))
+		assert.Equal(t, "gs://bucket/d.txt", Dir("gs://bucket").PathTo("d.txt"))
+		assert.Equal(t, "https://example.com/a/d.txt", Dir("https://example.com/a").PathTo("d.txt"))
+	})
 }
 
 func TestDir_SubDir(t *testing.T) {
@@ -48,4 +59,8 @@ func TestDir_SubDir(t *testing.T) {
 		s := d.SubDir("d")
 		assert.Equal(t, "/a/b/c/d", s)
 	})
+
+	t.Run("should keep the scheme and host of a URL", func(t *testing.T) {
+		assert.Equal(t, "gs://bucket/run/d", Dir("gs://bucket/run").SubDir("d"))
+	})
 }
@@ -2,21 +2,30 @@ package files
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
 
 	"github.com/glesica/flowork/internal/pkg/option"
 )
 
 // Gcs provides a Store interface that can handle files stored in
-// Google Cloud Storage (GCS).
+// Google Cloud Storage (GCS). Paths look like gs://bucket/object.
 type Gcs struct {
+	lock   sync.Mutex
 	client *storage.Client
 }
 
+// NewGcs creates a GCS store. Unless a client is given, one is created,
+// when it is first needed, using the application default credentials,
+// or the emulator named by STORAGE_EMULATOR_HOST, if it is set.
 func NewGcs(opts ...option.Func[*Gcs]) (*Gcs, error) {
 	s := &Gcs{}
 	err := option.Apply(s, opts...)
@@ -24,8 +33,6 @@ func NewGcs(opts ...option.Func[*Gcs]) (*Gcs, error) {
 		return nil, fmt.Errorf("NewGcs: failed to apply options: %w", err)
 	}
 
-	// TODO: Create a client if one wasn't provided
-
 	return s, nil
 }
 
@@ -57,38 +64,214 @@ func (s *Gcs) Accepts(p Path) bool {
 	return true
 }
 
+// getClient returns the client, creating it if necessary. Creating it
+// lazily means credentials are only needed if GCS is actually used.
+func (s *Gcs) getClient() (*storage.Client, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.client == nil {
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS client: %w", err)
+		}
+		s.client = client
+	}
+
+	return s.client, nil
+}
+
+// object returns a handle to the object the path refers to.
+func (s *Gcs) object(p Path) (*storage.ObjectHandle, error) {
+	bucket, name, err := splitGcsPath(string(p))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Bucket(bucket).Object(name), nil
+}
+
+// splitGcsPath returns the bucket and object name (or prefix) from a
+// gs:// URL. Object names don't start with a slash.
+func splitGcsPath(p string) (bucket, name string, err error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse gs url: %w", err)
+	}
+
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
 func (s *Gcs) Load(p Path) (io.ReadCloser, error) {
-	u, err := url.Parse(string(p))
+	o, err := s.object(p)
 	if err != nil {
-		return nil, fmt.Errorf("Gcs.Load: failed to parse gs url: %w", err)
+		return nil, fmt.Errorf("Gcs.Load: %w", err)
 	}
 
-	b := s.client.Bucket(u.Host)
-	o := b.Object(u.Path)
+	r, err := o.NewReader(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Gcs.Load: failed to read %s: %w", p, err)
+	}
 
-	return o.NewReader(context.Background())
+	return r, nil
 }
 
 func (s *Gcs) Save(p Path, f io.Reader) error {
-	u, err := url.Parse(string(p))
+	o, err := s.object(p)
 	if err != nil {
-		return fmt.Errorf("Gcs.Save: failed to parse gs url: %w", err)
+		return fmt.Errorf("Gcs.Save: %w", err)
 	}
 
-	b := s.client.Bucket(u.Host)
-	o := b.Object(u.Path)
-	r := o.NewWriter(context.Background())
-	defer func() { _ = r.Close() }()
+	// Cancelling the context abandons the upload, so that a partial
+	// object isn't left behind if the copy fails.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	_, err = io.Copy(r, f)
+	w := o.NewWriter(ctx)
+
+	_, err = io.Copy(w, f)
 	if err != nil {
+		cancel()
+		_ = w.Close()
 		return fmt.Errorf("Gcs.Save: failed to copy file: %w", err)
 	}
 
+	// The upload isn't complete, and may still fail, until the writer
+	// has been closed.
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("Gcs.Save: failed to upload %s: %w", p, err)
+	}
+
 	return nil
 }
 
+func (s *Gcs) Size(p Path) (Size, error) {
+	o, err := s.object(p)
+	if err != nil {
+		return SizeUnknown, fmt.Errorf("Gcs.Size: %w", err)
+	}
+
+	attrs, err := o.Attrs(context.Background())
+	if err != nil {
+		return SizeUnknown, fmt.Errorf("Gcs.Size: failed to get attributes for %s: %w", p, err)
+	}
+
+	return Size(attrs.Size), nil
+}
+
+func (s *Gcs) Stat(p Path) (Info, error) {
+	o, err := s.object(p)
+	if err != nil {
+		return Info{}, fmt.Errorf("Gcs.Stat: %w", err)
+	}
+
+	attrs, err := o.Attrs(context.Background())
+	if err != nil {
+		return Info{}, fmt.Errorf("Gcs.Stat: failed to get attributes for %s: %w", p, err)
+	}
+
+	return Info{
+		Path:    p,
+		Size:    Size(attrs.Size),
+		ModTime: attrs.Updated,
+	}, nil
+}
+
+// Checksums reports the CRC32C that GCS keeps for every object, and
+// the MD5, which composite objects don't have.
+func (s *Gcs) Checksums(p Path) (Checksums, error) {
+	o, err := s.object(p)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("Gcs.Checksums: %w", err)
+	}
+
+	attrs, err := o.Attrs(context.Background())
+	if err != nil {
+		return Checksums{}, fmt.Errorf("Gcs.Checksums: failed to get attributes for %s: %w", p, err)
+	}
+
+	var sums Checksums
+	if attrs.CRC32C != 0 || attrs.Size == 0 {
+		// A zero for a non-empty object means it wasn't recorded,
+		// which happens with emulators.
+		sums.CRC32C = fmt.Sprintf("%08x", attrs.CRC32C)
+	}
+	if len(attrs.MD5) > 0 {
+		sums.MD5 = hex.EncodeToString(attrs.MD5)
+	}
+
+	return sums, nil
+}
+
+// List returns the objects whose names start with the directory, as a
+// prefix, and contain no further slashes.
+func (s *Gcs) List(d Dir) ([]Info, error) {
+	bucket, prefix, err := splitGcsPath(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("Gcs.List: %w", err)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	client, err := s.getClient()
+	if err != nil {
+		return nil, fmt.Errorf("Gcs.List: %w", err)
+	}
+
+	it := client.Bucket(bucket).Objects(context.Background(), &storage.Query{
+		Prefix:    prefix,
+		Delimiter: "/",
+	})
+
+	var infos []Info
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Gcs.List: failed to list %s: %w", d, err)
+		}
+
+		// Subdirectories are reported as prefixes, and some tools
+		// create empty objects to stand in for directories.
+		if attrs.Prefix != "" || attrs.Name == prefix {
+			continue
+		}
+
+		infos = append(infos, Info{
+			Path:    Path(fmt.Sprintf("gs://%s/%s", bucket, attrs.Name)),
+			Size:    Size(attrs.Size),
+			ModTime: attrs.Updated,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+
+	return infos, nil
+}
+
+func (s *Gcs) Env(p Path) Env {
+	return EnvGcs
+}
+
 func (s *Gcs) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+
 	err := s.client.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close Gcs: %w", err)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -117,3 +118,109 @@ func TestGcs_Load(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// newFakeGcs runs the fake GCS server and returns a store that uses it.
+func newFakeGcs(t *testing.T) *Gcs {
+	server, err := runFakeGcs()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	gcsClient, err := gcsemu.NewClient(context.Background())
+	assert.NoError(t, err)
+
+	b, err := NewGcs(WithGcsClient(gcsClient))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+
+	return b
+}
+
+func TestGcs_Save(t *testing.T) {
+	b := newFakeGcs(t)
+
+	p := Path(fmt.Sprintf("gs://%s/dir/saved.txt", testBucket))
+	err := b.Save(p, strings.NewReader("saved"))
+	assert.NoError(t, err)
+
+	t.Run("should use an object name without a leading slash", func(t *testing.T) {
+		gcsClient, err := gcsemu.NewClient(context.Background())
+		assert.NoError(t, err)
+		defer func() { _ = gcsClient.Close() }()
+
+		r, err := gcsClient.Bucket(testBucket).Object("dir/saved.txt").NewReader(context.Background())
+		assert.NoError(t, err)
+		defer func() { _ = r.Close() }()
+
+		content, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "saved", string(content))
+	})
+}
+
+func TestGcs_List(t *testing.T) {
+	b := newFakeGcs(t)
+
+	for _, name := range []string{"in/b.txt", "in/a.txt", "in/sub/c.txt", "other/d.txt"} {
+		assert.NoError(t, addFakeFile(name, name))
+	}
+
+	for _, d := range []Dir{"in", "in/"} {
+		infos, err := b.List(Dir(fmt.Sprintf("gs://%s/%s", testBucket, d)))
+		assert.NoError(t, err)
+
+		var paths []Path
+		for _, i := range infos {
+			paths = append(paths, i.Path)
+			assert.Equal(t, Size(len("in/a.txt")), i.Size)
+		}
+		assert.Equal(t, []Path{
+			Path(fmt.Sprintf("gs://%s/in/a.txt", testBucket)),
+			Path(fmt.Sprintf("gs://%s/in/b.txt", testBucket)),
+		}, paths)
+	}
+}
+
+func TestNewGcs_defaultClient(t *testing.T) {
+	server, err := runFakeGcs()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	t.Setenv("STORAGE_EMULATOR_HOST", server.Addr)
+
+	assert.NoError(t, addFakeFile("default.txt", "abc"))
+
+	b, err := NewGcs()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+
+	f, err := b.Load(Path(fmt.Sprintf("gs://%s/default.txt", testBucket)))
+	assert.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(content))
+}
+
+func TestGcs_Checksums(t *testing.T) {
+	b := newFakeGcs(t)
+
+	src := writeHello(t)
+	dst := Path(fmt.Sprintf("gs://%s/hello.txt", testBucket))
+
+	_, sums, err := Copy(b, dst, &Local{}, src)
+	assert.NoError(t, err)
+	assert.Equal(t, helloChecksums, sums)
+
+	// The emulator only records MD5 checksums.
+	reported, err := b.Checksums(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, Checksums{MD5: helloChecksums.MD5}, reported)
+}
@@ -5,16 +5,36 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/glesica/flowork/internal/pkg/option"
 )
 
+// Http provides a Store interface to files served over HTTP(S). Files
+// are loaded with GET requests, and saved with PUT (or POST) requests,
+// so servers must support those to be used for outputs. Requests that
+// fail with a network error, or a 429 or 5xx status, are retried if
+// they are idempotent.
 type Http struct {
-	client *http.Client
+	client     *http.Client
+	saveMethod string
+	header     http.Header
+	retries    int
+	retryDelay time.Duration
+
+	// sign, if set, is applied to every request just before it is
+	// sent, so that stores built on Http, like S3, can authenticate
+	// each attempt.
+	sign func(r *http.Request) error
 }
 
 func NewHttp(opts ...option.Func[*Http]) (*Http, error) {
-	s := &Http{}
+	s := &Http{
+		saveMethod: http.MethodPut,
+		header:     http.Header{},
+		retries:    2,
+		retryDelay: time.Second,
+	}
 	err := option.Apply(s, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("NewHttp: failed to apply options: %w", err)
@@ -34,13 +54,80 @@ func WithClient(client *http.Client) option.Func[*Http] {
 	}
 }
 
+// WithSaveMethod sets the method used to save files, either PUT, the
+// default, or POST. POST requests are never retried.
+func WithSaveMethod(method string) option.Func[*Http] {
+	return func(s *Http) error {
+		method = strings.ToUpper(method)
+		if method != http.MethodPut && method != http.MethodPost {
+			return fmt.Errorf("unsupported save method (%s)", method)
+		}
+		s.saveMethod = method
+		return nil
+	}
+}
+
+// WithHeader adds a header that is sent with every request.
+func WithHeader(key, value string) option.Func[*Http] {
+	return func(s *Http) error {
+		s.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithBearerToken authenticates every request with the given token.
+func WithBearerToken(token string) option.Func[*Http] {
+	return func(s *Http) error {
+		s.header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// WithBasicAuth authenticates every request with the given user name
+// and password.
+func WithBasicAuth(user, password string) option.Func[*Http] {
+	return func(s *Http) error {
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(user, password)
+		s.header.Set("Authorization", r.Header.Get("Authorization"))
+		return nil
+	}
+}
+
+// WithRetries sets the number of times a failed request is retried,
+// the default is 2. The delay between attempts starts at the given
+// value and doubles each time.
+func WithRetries(retries int, delay time.Duration) option.Func[*Http] {
+	return func(s *Http) error {
+		if retries < 0 {
+			return fmt.Errorf("retries must not be negative: %d", retries)
+		}
+		s.retries = retries
+		s.retryDelay = delay
+		return nil
+	}
+}
+
+// HttpStatusError is returned when a server responds to a request
+// with a status other than 2xx.
+type HttpStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HttpStatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+}
+
 func (h *Http) Accepts(p Path) bool {
 	u := string(p)
 	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
 }
 
 func (h *Http) Load(p Path) (io.ReadCloser, error) {
-	resp, err := h.client.Get(string(p))
+	resp, err := h.do(http.MethodGet, p, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Http.Load: error fetching %s: %w", p, err)
 	}
@@ -48,12 +135,155 @@ func (h *Http) Load(p Path) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+func (h *Http) Size(p Path) (Size, error) {
+	resp, err := h.do(http.MethodHead, p, nil)
+	if err != nil {
+		return SizeUnknown, fmt.Errorf("Http.Size: error fetching %s: %w", p, err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.ContentLength < 0 {
+		return SizeUnknown, nil
+	}
+
+	return Size(resp.ContentLength), nil
+}
+
+// Stat describes the file from the headers of a HEAD request. The
+// modification time is only known if the server sends Last-Modified.
+func (h *Http) Stat(p Path) (Info, error) {
+	resp, err := h.do(http.MethodHead, p, nil)
+	if err != nil {
+		return Info{}, fmt.Errorf("Http.Stat: error fetching %s: %w", p, err)
+	}
+	_ = resp.Body.Close()
+
+	return responseInfo(p, resp), nil
+}
+
+func (h *Http) Env(p Path) Env {
+	return EnvHttp
+}
+
+// Save uploads the file. The request can only be retried if the data
+// can be read again, which is the case for files, otherwise a single
+// attempt is made.
 func (h *Http) Save(p Path, f io.Reader) error {
-	//TODO implement me
-	panic("implement me")
+	resp, err := h.do(h.saveMethod, p, f)
+	if err != nil {
+		return fmt.Errorf("Http.Save: error saving %s: %w", p, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return nil
 }
 
 func (h *Http) Close() error {
 	h.client.CloseIdleConnections()
 	return nil
 }
+
+// do makes a request, retrying it if it fails and it is safe to do so,
+// and returns the response if its status is 2xx. The caller must close
+// the response body.
+func (h *Http) do(method string, p Path, body io.Reader) (*http.Response, error) {
+	retries := h.retries
+	length := int64(-1)
+	seeker, seekable := body.(io.Seeker)
+	if seekable {
+		// Knowing the length lets the body be sent with a
+		// Content-Length, which some servers require.
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = seeker.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get request body length: %w", err)
+		}
+		length = end
+	}
+	if method == http.MethodPost || body != nil && !seekable {
+		retries = 0
+	}
+
+	delay := h.retryDelay
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+
+			if seekable {
+				_, err := seeker.Seek(0, io.SeekStart)
+				if err != nil {
+					return nil, fmt.Errorf("failed to rewind request body: %w", err)
+				}
+			}
+		}
+
+		resp, err := h.attempt(method, p, body, length)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= retries || !retryable(err) {
+			return nil, err
+		}
+	}
+}
+
+func (h *Http) attempt(method string, p Path, body io.Reader, length int64) (*http.Response, error) {
+	if body != nil {
+		// Keep the client from closing the caller's reader.
+		body = io.NopCloser(body)
+	}
+
+	req, err := http.NewRequest(method, string(p), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range h.header {
+		req.Header[k] = vs
+	}
+	if length >= 0 {
+		req.ContentLength = length
+		if length == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	if h.sign != nil {
+		err := h.sign(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return nil, &HttpStatusError{
+			Method:     method,
+			URL:        string(p),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	return resp, nil
+}
+
+// retryable indicates whether a failed request might succeed if it
+// were tried again.
+func retryable(err error) bool {
+	se, ok := err.(*HttpStatusError)
+	if !ok {
+		// Network errors are generally transient.
+		return true
+	}
+
+	return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+}
@@ -0,0 +1,205 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// failingHandler responds with the given status to the first failures
+// requests, then passes requests on to the handler.
+func failingHandler(failures int32, status int, next http.HandlerFunc) (http.HandlerFunc, *atomic.Int32) {
+	var calls atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		next(w, r)
+	}, &calls
+}
+
+func newTestHttp(t *testing.T, opts ...option.Func[*Http]) *Http {
+	t.Helper()
+
+	h, err := NewHttp(append([]option.Func[*Http]{WithRetries(2, time.Millisecond)}, opts...)...)
+	assert.NoError(t, err)
+
+	return h
+}
+
+func TestHttp_Load(t *testing.T) {
+	t.Run("should load a file", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "data")
+		}))
+		defer srv.Close()
+
+		r, err := newTestHttp(t).Load(Path(srv.URL + "/file.txt"))
+		assert.NoError(t, err)
+		defer func() { _ = r.Close() }()
+
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "data", string(data))
+	})
+
+	t.Run("should fail on an error status", func(t *testing.T) {
+		handler, calls := failingHandler(10, http.StatusNotFound, nil)
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		_, err := newTestHttp(t).Load(Path(srv.URL + "/missing.txt"))
+		var se *HttpStatusError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusNotFound, se.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("should retry server errors", func(t *testing.T) {
+		handler, calls := failingHandler(2, http.StatusServiceUnavailable, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "data")
+		})
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		r, err := newTestHttp(t).Load(Path(srv.URL + "/file.txt"))
+		assert.NoError(t, err)
+		_ = r.Close()
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("should give up after the retries", func(t *testing.T) {
+		handler, calls := failingHandler(10, http.StatusInternalServerError, nil)
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		_, err := newTestHttp(t).Load(Path(srv.URL + "/file.txt"))
+		assert.Error(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+}
+
+func TestHttp_Save(t *testing.T) {
+	t.Run("should put the file with auth and headers", func(t *testing.T) {
+		var got *http.Request
+		var body []byte
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer srv.Close()
+
+		h := newTestHttp(t, WithBearerToken("secret"), WithHeader("X-Flowork", "yes"))
+		err := h.Save(Path(srv.URL+"/out.txt"), bytes.NewReader([]byte("output")))
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.MethodPut, got.Method)
+		assert.Equal(t, "/out.txt", got.URL.Path)
+		assert.Equal(t, "Bearer secret", got.Header.Get("Authorization"))
+		assert.Equal(t, "yes", got.Header.Get("X-Flowork"))
+		assert.Equal(t, int64(6), got.ContentLength)
+		assert.Equal(t, "output", string(body))
+	})
+
+	t.Run("should post the file with basic auth", func(t *testing.T) {
+		var method, user, password string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+			user, password, _ = r.BasicAuth()
+		}))
+		defer srv.Close()
+
+		h := newTestHttp(t, WithSaveMethod("post"), WithBasicAuth("bob", "hunter2"))
+		err := h.Save(Path(srv.URL+"/out.txt"), strings.NewReader("output"))
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.MethodPost, method)
+		assert.Equal(t, "bob", user)
+		assert.Equal(t, "hunter2", password)
+	})
+
+	t.Run("should resend the file when retrying", func(t *testing.T) {
+		var body []byte
+		handler, calls := failingHandler(1, http.StatusBadGateway, func(w http.ResponseWriter, r *http.Request) {
+			body, _ = io.ReadAll(r.Body)
+		})
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		err := newTestHttp(t).Save(Path(srv.URL+"/out.txt"), strings.NewReader("output"))
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, "output", string(body))
+	})
+
+	t.Run("should not retry requests that can't be repeated", func(t *testing.T) {
+		handler, calls := failingHandler(10, http.StatusBadGateway, nil)
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		err := newTestHttp(t, WithSaveMethod(http.MethodPost)).Save(Path(srv.URL+"/out.txt"), strings.NewReader("output"))
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+
+		// Without a Seeker, the data can't be sent again.
+		err = newTestHttp(t).Save(Path(srv.URL+"/out.txt"), io.MultiReader(strings.NewReader("output")))
+		assert.Error(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("should reject unsupported methods", func(t *testing.T) {
+		_, err := NewHttp(WithSaveMethod("PATCH"))
+		assert.Error(t, err)
+	})
+}
+
+func TestHttp_Size(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "42")
+	}))
+	defer srv.Close()
+
+	size, err := newTestHttp(t).Size(Path(srv.URL + "/file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, Size(42), size)
+
+	_, err = newTestHttp(t).Size(Path(srv.URL + "/missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestHttp_Stat(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "42")
+		if r.URL.Path == "/dated.txt" {
+			w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		}
+	}))
+	defer srv.Close()
+
+	info, err := newTestHttp(t).Stat(Path(srv.URL + "/dated.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, Size(42), info.Size)
+	assert.True(t, modTime.Equal(info.ModTime))
+
+	info, err = newTestHttp(t).Stat(Path(srv.URL + "/undated.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, Size(42), info.Size)
+	assert.True(t, info.ModTime.IsZero())
+}
@@ -0,0 +1,25 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Lister is a Store that can list the files in a directory. In
+// object stores, a directory is a prefix that ends with a slash.
+type Lister interface {
+	// List returns the files directly in the given directory, not
+	// those in its subdirectories, sorted by path.
+	List(d Dir) ([]Info, error)
+}
+
+// List returns the files directly in the given directory if the store
+// supports it, and an error wrapping errors.ErrUnsupported otherwise.
+func List(s Store, d Dir) ([]Info, error) {
+	lister, ok := s.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("cannot list %s: %w", d, errors.ErrUnsupported)
+	}
+
+	return lister.List(d)
+}
@@ -59,6 +59,75 @@ func (l *Local) Save(p Path, f io.Reader) error {
 	return nil
 }
 
+func (l *Local) Size(p Path) (Size, error) {
+	if err := l.accepts(p); err != nil {
+		return SizeUnknown, fmt.Errorf("Local.Size: %w", err)
+	}
+
+	info, err := os.Stat(string(p))
+	if err != nil {
+		return SizeUnknown, fmt.Errorf("Local.Size: failed to stat %s: %w", p, err)
+	}
+
+	return Size(info.Size()), nil
+}
+
+func (l *Local) Stat(p Path) (Info, error) {
+	if err := l.accepts(p); err != nil {
+		return Info{}, fmt.Errorf("Local.Stat: %w", err)
+	}
+
+	info, err := os.Stat(string(p))
+	if err != nil {
+		return Info{}, fmt.Errorf("Local.Stat: failed to stat %s: %w", p, err)
+	}
+
+	return Info{
+		Path:    p,
+		Size:    Size(info.Size()),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// List returns the normal files in the directory, including those that
+// symbolic links point to.
+func (l *Local) List(d Dir) ([]Info, error) {
+	if err := l.accepts(Path(d)); err != nil {
+		return nil, fmt.Errorf("Local.List: %w", err)
+	}
+
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("Local.List: failed to read %s: %w", d, err)
+	}
+
+	var infos []Info
+	for _, e := range entries {
+		p := d.PathTo(e.Name())
+
+		info, err := os.Stat(string(p))
+		if err != nil {
+			// The file was removed, or is a broken link.
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		infos = append(infos, Info{
+			Path:    p,
+			Size:    Size(info.Size()),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	return infos, nil
+}
+
+func (l *Local) Env(p Path) Env {
+	return EnvLocal
+}
+
 func (l *Local) Close() error {
 	return nil
 }
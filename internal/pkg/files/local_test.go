@@ -1,7 +1,55 @@
 package files
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
 
 func TestLocal_Accepts(t *testing.T) {
 
 }
+
+func TestLocal_List(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("bb"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "link.txt")))
+	assert.NoError(t, os.Symlink("missing.txt", filepath.Join(dir, "broken.txt")))
+
+	infos, err := (&Local{}).List(Dir(dir))
+	assert.NoError(t, err)
+
+	var names []string
+	var sizes []Size
+	for _, i := range infos {
+		names = append(names, i.Path.File())
+		sizes = append(sizes, i.Size)
+		assert.False(t, i.ModTime.IsZero())
+	}
+	assert.Equal(t, []string{"a.txt", "b.txt", "link.txt"}, names)
+	assert.Equal(t, []Size{1, 2, 1}, sizes)
+
+	t.Run("should reject relative directories", func(t *testing.T) {
+		_, err := (&Local{}).List("relative")
+		assert.Error(t, err)
+	})
+}
+
+func TestLocal_Stat(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	assert.NoError(t, os.WriteFile(p, []byte("abc"), 0644))
+
+	info, err := (&Local{}).Stat(Path(p))
+	assert.NoError(t, err)
+	assert.Equal(t, Path(p), info.Path)
+	assert.Equal(t, Size(3), info.Size)
+	assert.False(t, info.ModTime.IsZero())
+
+	_, err = (&Local{}).Stat(Path(filepath.Join(dir, "missing.txt")))
+	assert.Error(t, err)
+}
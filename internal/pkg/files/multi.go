@@ -61,6 +61,56 @@ func (m *Multi) Save(p Path, data io.Reader) error {
 	return fmt.Errorf("cannot save unsupported path %s", p)
 }
 
+func (m *Multi) Size(p Path) (Size, error) {
+	for _, c := range m.stores {
+		if c.Accepts(p) {
+			return SizeOf(c, p)
+		}
+	}
+
+	return SizeUnknown, fmt.Errorf("cannot size unsupported path %s", p)
+}
+
+func (m *Multi) Stat(p Path) (Info, error) {
+	for _, c := range m.stores {
+		if c.Accepts(p) {
+			return StatOf(c, p)
+		}
+	}
+
+	return Info{}, fmt.Errorf("cannot stat unsupported path %s", p)
+}
+
+func (m *Multi) Checksums(p Path) (Checksums, error) {
+	for _, c := range m.stores {
+		if c.Accepts(p) {
+			return ChecksumsOf(c, p)
+		}
+	}
+
+	return Checksums{}, fmt.Errorf("cannot checksum unsupported path %s", p)
+}
+
+func (m *Multi) List(d Dir) ([]Info, error) {
+	for _, c := range m.stores {
+		if c.Accepts(Path(d)) {
+			return List(c, d)
+		}
+	}
+
+	return nil, fmt.Errorf("cannot list unsupported directory %s", d)
+}
+
+func (m *Multi) Env(p Path) Env {
+	for _, c := range m.stores {
+		if c.Accepts(p) {
+			return EnvOf(c, p)
+		}
+	}
+
+	return EnvUnknown
+}
+
 func (m *Multi) Close() error {
 	var errs []error
 	for _, s := range m.stores {
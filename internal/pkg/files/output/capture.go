@@ -1,36 +1,58 @@
 package output
 
 import (
-	"bytes"
 	"io"
 	"log/slog"
 
 	"github.com/glesica/flowork/internal/pkg/files"
 )
 
-// TODO: Need to stream data into the writers from outside, so need a "done" signal
-// Empty buffers return EOF on read, so that won't work because the buffer could
-// be temporarily empty
+// Writers returns a pair of writers that stream whatever is written to
+// them into stdout.txt and stderr.txt in the given directory, using
+// the given store. The files are written as data arrives, and each
+// writer must be closed to signal that there is no more data. Close
+// blocks until the file has been saved and returns any error that
+// occurred while saving it.
+func Writers(s files.Store, dest files.Dir) (stdout io.WriteCloser, stderr io.WriteCloser, err error) {
+	stdout = Stream(s, dest.PathTo("stdout.txt"))
+	stderr = Stream(s, dest.PathTo("stderr.txt"))
 
-func Writers(s files.Store, dest files.Dir) (stdout io.Writer, stderr io.Writer, err error) {
-	stdoutPath := dest.PathTo("stdout.txt")
-	stderrPath := dest.PathTo("stderr.txt")
+	return stdout, stderr, nil
+}
 
-	stdoutBuf := &bytes.Buffer{}
-	stderrBuf := &bytes.Buffer{}
+// Stream returns a writer that streams whatever is written to it into
+// the file at the given path, using the given store. See Writers.
+func Stream(s files.Store, p files.Path) io.WriteCloser {
+	pr, pw := io.Pipe()
+	w := &streamWriter{
+		pipe: pw,
+		done: make(chan error, 1),
+	}
 
 	go func() {
-		err := s.Save(stdoutPath, stdoutBuf)
-		if err != nil {
-			slog.Error("failed to save stdout (%s): %w", stdoutPath, err)
-		}
-	}()
-	go func() {
-		err := s.Save(stderrPath, stderrBuf)
+		err := s.Save(p, pr)
 		if err != nil {
-			slog.Error("failed to save stderr (%s): %w", stderrPath, err)
+			slog.Error("failed to save stream", "path", p, "error", err)
 		}
+
+		// Unblock any pending writes if the save ended early.
+		_ = pr.CloseWithError(err)
+		w.done <- err
 	}()
 
-	return stdoutBuf, stderrBuf, nil
+	return w
+}
+
+type streamWriter struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+func (w *streamWriter) Close() error {
+	_ = w.pipe.Close()
+	return <-w.done
 }
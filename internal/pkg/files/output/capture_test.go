@@ -0,0 +1,48 @@
+package output
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestWriters(t *testing.T) {
+	dir := files.Dir(t.TempDir())
+
+	stdout, stderr, err := Writers(&files.Local{}, dir)
+	assert.NoError(t, err)
+
+	_, err = io.WriteString(stdout, "out 1\n")
+	assert.NoError(t, err)
+	_, err = io.WriteString(stderr, "err\n")
+	assert.NoError(t, err)
+	_, err = io.WriteString(stdout, "out 2\n")
+	assert.NoError(t, err)
+
+	assert.NoError(t, stdout.Close())
+	assert.NoError(t, stderr.Close())
+
+	content, err := os.ReadFile(filepath.Join(string(dir), "stdout.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "out 1\nout 2\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(string(dir), "stderr.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "err\n", string(content))
+}
+
+func TestStream(t *testing.T) {
+	t.Run("should fail writes when the save fails", func(t *testing.T) {
+		// Local refuses relative paths, so the save fails immediately.
+		w := Stream(&files.Local{}, "relative/stdout.txt")
+
+		_, err := io.WriteString(w, "data")
+		assert.Error(t, err)
+		assert.Error(t, w.Close())
+	})
+}
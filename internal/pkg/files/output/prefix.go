@@ -0,0 +1,73 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Prefix returns a writer that writes each line it receives to the
+// given writer with the given prefix prepended. Lines are written
+// whole, in a single call, so several prefixed writers can share
+// the same underlying writer (such as a terminal) without their
+// lines getting mixed together.
+//
+// Closing the writer flushes any incomplete final line, but does not
+// close the underlying writer.
+func Prefix(w io.Writer, prefix string) io.WriteCloser {
+	return &prefixWriter{
+		dest:   w,
+		prefix: []byte(prefix),
+	}
+}
+
+type prefixWriter struct {
+	lock   sync.Mutex
+	dest   io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := w.writeLine(w.buf[:i+1])
+		if err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (w *prefixWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	err := w.writeLine(append(w.buf, '\n'))
+	w.buf = nil
+
+	return err
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	out := make([]byte, 0, len(w.prefix)+len(line))
+	out = append(out, w.prefix...)
+	out = append(out, line...)
+
+	_, err := w.dest.Write(out)
+	return err
+}
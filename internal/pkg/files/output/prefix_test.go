@@ -0,0 +1,36 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestPrefix(t *testing.T) {
+	t.Run("should prefix complete lines", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := Prefix(buf, "[task/job] ")
+
+		_, err := io.WriteString(w, "one\ntw")
+		assert.NoError(t, err)
+		assert.Equal(t, "[task/job] one\n", buf.String())
+
+		_, err = io.WriteString(w, "o\n")
+		assert.NoError(t, err)
+		assert.Equal(t, "[task/job] one\n[task/job] two\n", buf.String())
+	})
+
+	t.Run("should flush a partial line on close", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := Prefix(buf, "> ")
+
+		_, err := io.WriteString(w, "partial")
+		assert.NoError(t, err)
+		assert.Equal(t, "", buf.String())
+
+		assert.NoError(t, w.Close())
+		assert.Equal(t, "> partial\n", buf.String())
+	})
+}
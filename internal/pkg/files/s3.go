@@ -1 +1,505 @@
 package files
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// S3 provides a Store interface to files stored in Amazon S3, or a
+// compatible service, like MinIO. Paths look like s3://bucket/key.
+// Requests are made over HTTPS and signed with AWS Signature Version
+// 4, or sent unsigned if there are no credentials, which works for
+// public buckets. Files are saved with a single PUT, so they can be
+// no larger than 5 GiB.
+type S3 struct {
+	http *Http
+
+	region   string
+	endpoint string
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+
+	// now is used to date signatures, it is replaced by tests.
+	now func() time.Time
+}
+
+// NewS3 creates an S3 store. By default, the credentials are taken
+// from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN,
+// the region from AWS_REGION or AWS_DEFAULT_REGION (or us-east-1), and
+// the endpoint from AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL.
+func NewS3(opts ...option.Func[*S3]) (*S3, error) {
+	s := &S3{
+		region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		endpoint:     firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		now:          time.Now,
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+
+	h, err := NewHttp()
+	if err != nil {
+		return nil, fmt.Errorf("NewS3: failed to create HTTP client: %w", err)
+	}
+	s.http = h
+
+	err = option.Apply(s, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("NewS3: failed to apply options: %w", err)
+	}
+
+	s.http.sign = s.sign
+
+	return s, nil
+}
+
+// WithS3Region sets the region of the buckets.
+func WithS3Region(region string) option.Func[*S3] {
+	return func(s *S3) error {
+		if region != "" {
+			s.region = region
+		}
+		return nil
+	}
+}
+
+// WithS3Endpoint sets the URL of an S3-compatible service to use
+// instead of AWS. Buckets are addressed by path, as in
+// <endpoint>/<bucket>/<key>, which these services generally support.
+func WithS3Endpoint(endpoint string) option.Func[*S3] {
+	return func(s *S3) error {
+		if endpoint == "" {
+			return nil
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid S3 endpoint (%s)", endpoint)
+		}
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+		return nil
+	}
+}
+
+// WithS3Credentials sets the credentials used to sign requests. The
+// session token is only needed for temporary credentials.
+func WithS3Credentials(accessKey, secretKey, sessionToken string) option.Func[*S3] {
+	return func(s *S3) error {
+		s.accessKey = accessKey
+		s.secretKey = secretKey
+		s.sessionToken = sessionToken
+		return nil
+	}
+}
+
+// WithS3Http configures the HTTP store used to make requests, to set
+// the client or the retries, for example.
+func WithS3Http(opts ...option.Func[*Http]) option.Func[*S3] {
+	return func(s *S3) error {
+		return option.Apply(s.http, opts...)
+	}
+}
+
+func (s *S3) Accepts(p Path) bool {
+	_, _, err := splitS3Path(string(p))
+	return err == nil
+}
+
+// splitS3Path returns the bucket and key from an s3:// URL. Keys don't
+// start with a slash.
+func splitS3Path(p string) (bucket, key string, err error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid S3 path (%s): %w", p, err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 path (%s)", p)
+	}
+
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// bucketURL returns the HTTP(S) URL of the bucket, without a trailing
+// slash.
+func (s *S3) bucketURL(bucket string) string {
+	if s.endpoint != "" {
+		return s.endpoint + "/" + bucket
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, s.region)
+}
+
+// objectURL returns the HTTP(S) URL of the object, with the key
+// escaped the way signatures expect.
+func (s *S3) objectURL(bucket, key string) string {
+	return s.bucketURL(bucket) + escapeS3Path("/"+key)
+}
+
+func (s *S3) Load(p Path) (io.ReadCloser, error) {
+	bucket, key, err := splitS3Path(string(p))
+	if err != nil {
+		return nil, fmt.Errorf("S3.Load: %w", err)
+	}
+
+	resp, err := s.http.do(http.MethodGet, Path(s.objectURL(bucket, key)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("S3.Load: error fetching %s: %w", p, err)
+	}
+
+	return resp.Body, nil
+}
+
+// Save uploads the file. S3 needs to know the size of the file up
+// front, so data that can't be rewound is copied to a temporary file
+// first.
+func (s *S3) Save(p Path, f io.Reader) error {
+	bucket, key, err := splitS3Path(string(p))
+	if err != nil {
+		return fmt.Errorf("S3.Save: %w", err)
+	}
+
+	if _, ok := f.(io.Seeker); !ok {
+		tmp, err := os.CreateTemp("", "flowork-s3-*")
+		if err != nil {
+			return fmt.Errorf("S3.Save: failed to create temporary file: %w", err)
+		}
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+
+		_, err = io.Copy(tmp, f)
+		if err == nil {
+			_, err = tmp.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return fmt.Errorf("S3.Save: failed to buffer %s: %w", p, err)
+		}
+		f = tmp
+	}
+
+	resp, err := s.http.do(http.MethodPut, Path(s.objectURL(bucket, key)), f)
+	if err != nil {
+		return fmt.Errorf("S3.Save: error saving %s: %w", p, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+func (s *S3) Size(p Path) (Size, error) {
+	bucket, key, err := splitS3Path(string(p))
+	if err != nil {
+		return SizeUnknown, fmt.Errorf("S3.Size: %w", err)
+	}
+
+	resp, err := s.http.do(http.MethodHead, Path(s.objectURL(bucket, key)), nil)
+	if err != nil {
+		return SizeUnknown, fmt.Errorf("S3.Size: error fetching %s: %w", p, err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.ContentLength < 0 {
+		return SizeUnknown, nil
+	}
+
+	return Size(resp.ContentLength), nil
+}
+
+func (s *S3) Stat(p Path) (Info, error) {
+	bucket, key, err := splitS3Path(string(p))
+	if err != nil {
+		return Info{}, fmt.Errorf("S3.Stat: %w", err)
+	}
+
+	resp, err := s.http.do(http.MethodHead, Path(s.objectURL(bucket, key)), nil)
+	if err != nil {
+		return Info{}, fmt.Errorf("S3.Stat: error fetching %s: %w", p, err)
+	}
+	_ = resp.Body.Close()
+
+	return responseInfo(p, resp), nil
+}
+
+// Checksums reports the MD5 of the object, which S3 uses as its ETag
+// for objects that were uploaded in a single part and aren't encrypted
+// with KMS or a customer-provided key.
+func (s *S3) Checksums(p Path) (Checksums, error) {
+	bucket, key, err := splitS3Path(string(p))
+	if err != nil {
+		return Checksums{}, fmt.Errorf("S3.Checksums: %w", err)
+	}
+
+	resp, err := s.http.do(http.MethodHead, Path(s.objectURL(bucket, key)), nil)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("S3.Checksums: error fetching %s: %w", p, err)
+	}
+	_ = resp.Body.Close()
+
+	return Checksums{MD5: etagMD5(resp.Header)}, nil
+}
+
+// etagMD5 returns the ETag from the response headers for an object if
+// it is the MD5 of its contents, and an empty string otherwise.
+func etagMD5(h http.Header) string {
+	switch h.Get("X-Amz-Server-Side-Encryption") {
+	case "aws:kms", "aws:kms:dsse":
+		return ""
+	}
+	if h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return ""
+	}
+
+	// Multipart uploads have ETags like "<hash>-<parts>".
+	etag := strings.ToLower(strings.Trim(h.Get("ETag"), `"`))
+	if len(etag) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+
+	return etag
+}
+
+// s3ListResult is the part of a ListObjectsV2 response that is used.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the objects whose keys start with the directory, as a
+// prefix, and contain no further slashes.
+func (s *S3) List(d Dir) ([]Info, error) {
+	bucket, prefix, err := splitS3Path(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("S3.List: %w", err)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var infos []Info
+	token := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+			"delimiter": {"/"},
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.http.do(http.MethodGet, Path(s.bucketURL(bucket)+"?"+query.Encode()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("S3.List: failed to list %s: %w", d, err)
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("S3.List: invalid listing for %s: %w", d, err)
+		}
+
+		for _, c := range result.Contents {
+			// Some tools create empty objects to stand in for
+			// directories.
+			if c.Key == prefix {
+				continue
+			}
+
+			infos = append(infos, Info{
+				Path:    Path(fmt.Sprintf("s3://%s/%s", bucket, c.Key)),
+				Size:    Size(c.Size),
+				ModTime: c.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+
+	return infos, nil
+}
+
+func (s *S3) Env(p Path) Env {
+	return EnvS3
+}
+
+func (s *S3) Close() error {
+	return s.http.Close()
+}
+
+// unsignedPayload is used in place of a hash of the request body, so
+// that bodies can be streamed.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// sign adds an AWS Signature Version 4 Authorization header to the
+// request, unless there are no credentials.
+func (s *S3) sign(r *http.Request) error {
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	if r.Header.Get("X-Amz-Content-Sha256") == "" {
+		r.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+	if s.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for k, vs := range r.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(vs, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Send the path exactly as it was signed.
+	path := escapeS3Path(r.URL.Path)
+	if path == "" {
+		path = "/"
+	}
+	r.URL.RawPath = path
+
+	canonical := strings.Join([]string{
+		r.Method,
+		path,
+		canonicalS3Query(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSha256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSha256(key, s.region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, toSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+
+	return nil
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalS3Query encodes the query with its keys in order, as
+// signatures expect.
+func canonicalS3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, escapeS3(k)+"="+escapeS3(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// escapeS3Path escapes each segment of a path, leaving the slashes.
+func escapeS3Path(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = escapeS3(seg)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// escapeS3 escapes everything but unreserved characters, as AWS
+// signatures require.
+func escapeS3(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+// firstEnv returns the value of the first of the environment variables
+// that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
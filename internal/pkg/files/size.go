@@ -1,5 +1,47 @@
 package files
 
+import "fmt"
+
 type Size int64
 
 const SizeUnknown = Size(-1)
+
+// String formats the size using binary units, for use in logs and
+// other user-facing messages.
+func (s Size) String() string {
+	if s < 0 {
+		return "unknown"
+	}
+
+	const unit = 1024
+	if s < unit {
+		return fmt.Sprintf("%d B", s)
+	}
+
+	div, exp := int64(unit), 0
+	for n := int64(s) / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(s)/float64(div), "KMGTPE"[exp])
+}
+
+// A Sizer is a Store that can report the size of a file without
+// reading all of its contents.
+type Sizer interface {
+	// Size returns the size of the file at the given path, or
+	// SizeUnknown if the size cannot be determined cheaply.
+	Size(p Path) (Size, error)
+}
+
+// SizeOf returns the size of the file at the given path if the store
+// supports it, and SizeUnknown otherwise.
+func SizeOf(s Store, p Path) (Size, error) {
+	sizer, ok := s.(Sizer)
+	if !ok {
+		return SizeUnknown, nil
+	}
+
+	return sizer.Size(p)
+}
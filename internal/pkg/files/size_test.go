@@ -0,0 +1,25 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSize_String(t *testing.T) {
+	for _, tc := range []struct {
+		size     Size
+		expected string
+	}{
+		{SizeUnknown, "unknown"},
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.size.String())
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package files
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// A Stater is a Store that can describe a file, with its size and
+// modification time, without reading its contents.
+type Stater interface {
+	Stat(p Path) (Info, error)
+}
+
+// StatOf describes the file at the given path as well as the store
+// can. Stores that aren't a Stater only provide the size, if they are
+// a Sizer (see SizeOf).
+func StatOf(s Store, p Path) (Info, error) {
+	if stater, ok := s.(Stater); ok {
+		return stater.Stat(p)
+	}
+
+	size, err := SizeOf(s, p)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to get size of %s: %w", p, err)
+	}
+
+	return Info{Path: p, Size: size}, nil
+}
+
+// responseInfo describes a file from the headers of a response to a
+// request for it.
+func responseInfo(p Path, resp *http.Response) Info {
+	info := Info{Path: p, Size: SizeUnknown}
+	if resp.ContentLength >= 0 {
+		info.Size = Size(resp.ContentLength)
+	}
+
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err == nil {
+		info.ModTime = modTime
+	}
+
+	return info
+}
@@ -4,9 +4,12 @@ import (
 	"io"
 )
 
-// TODO: Add a way to find file size
 // TODO: Should paths be URIs?
 
+// Store provides access to files in a particular storage environment.
+// Implementations may also implement Sizer and Locator, which allow
+// callers to reason about the cost of moving data around, and
+// Checksummer, which allows copies to be verified (see Copy).
 type Store interface {
 	// Accepts indicates whether a given store can operate on the
 	// given path. It might do this, for example, by checking its
@@ -1,28 +1,30 @@
 package inputs
 
 import (
-	"path/filepath"
-	"regexp"
-	"strings"
-
 	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/spec"
 )
 
-type Filter func(p files.Path) bool
+// Filter reports whether a file should be used as an input. Filters
+// are usually compiled from the filters declared by a workflow (see
+// WithSpec).
+type Filter func(i files.Info) bool
+
+// WithSpec compiles the include and exclude filters declared by a
+// workflow (see spec.CompileFilters).
+func WithSpec(include, exclude []spec.Filter) (Filter, error) {
+	return spec.CompileFilters(include, exclude)
+}
 
-func WithRegexp(e string) Filter {
-	r := regexp.MustCompile(e)
-	return func(p files.Path) bool {
-		return r.MatchString(string(p))
-	}
+// WithRegexp accepts files whose full path matches the regular
+// expression.
+func WithRegexp(e string) (Filter, error) {
+	return spec.Filter{Path: e}.Compile()
 }
 
+// WithExt accepts files with the given extension, which may have more
+// than one part, like "fastq.gz".
 func WithExt(e string) Filter {
-	if !strings.HasPrefix(e, ".") {
-		e = "." + e
-	}
-	return func(p files.Path) bool {
-		ae := filepath.Ext(string(p))
-		return e == ae
-	}
+	f, _ := spec.Filter{Ext: e}.Compile()
+	return f
 }
@@ -1,11 +1,13 @@
 package inputs
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
 
 	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/spec"
 )
 
 func TestWithRegexp(t *testing.T) {
@@ -15,10 +17,18 @@ func TestWithRegexp(t *testing.T) {
 		outcome bool
 	}{
 		{"/dir/file", `^/dir/[a-z]+$`, true},
+		{"/dir/file1", `^/dir/[a-z]+$`, false},
 	} {
-		f := WithRegexp(c.regex)
-		assert.Equal(t, c.outcome, f(c.name))
+		f, err := WithRegexp(c.regex)
+		assert.NoError(t, err)
+		assert.Equal(t, c.outcome, f(files.Info{Path: c.name}))
 	}
+
+	t.Run("should return an error for a bad regex", func(t *testing.T) {
+		_, err := WithRegexp(`(`)
+		var fe *spec.FilterError
+		assert.True(t, errors.As(err, &fe))
+	})
 }
 
 func TestWithExt(t *testing.T) {
@@ -32,8 +42,10 @@ func TestWithExt(t *testing.T) {
 		{"/dir/filefoo", "foo", false},
 		{"foo", "foo", false},
 		{"file.foo", "foo", true},
+		{"/dir/file.fastq.gz", "fastq.gz", true},
+		{"/dir/file.fastq.gz", ".gz", true},
 	} {
 		f := WithExt(c.ext)
-		assert.Equal(t, c.outcome, f(c.name))
+		assert.Equal(t, c.outcome, f(files.Info{Path: c.name}))
 	}
 }
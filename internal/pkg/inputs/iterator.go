@@ -1,63 +1,104 @@
 package inputs
 
 import (
-	"log/slog"
+	"context"
+	"sync"
 
 	"github.com/glesica/flowork/internal/pkg/files"
 )
 
-// Iterator iterates over a collection of Paths
-// and sends each over the channel it returns. The function
-// returned can be called to stop iteration early. If iteration
-// is allowed to complete normally, the function need not be called.
-// Either way, the channel will be closed when iteration has finished.
-type Iterator func() (in <-chan files.Path, cancel func(), err error)
+// Iterator starts iterating over a collection of Paths, which are sent
+// over the channel of the Stream it returns. Iteration stops early if
+// the context is cancelled, or the Stream is cancelled. Either way,
+// the channel will be closed when iteration has finished.
+type Iterator func(ctx context.Context) (*Stream, error)
+
+// Stream is an iteration that is underway. Once its channel has been
+// closed, Err reports whether the iteration stopped because of a
+// problem, rather than because it ran out of paths.
+type Stream struct {
+	paths  <-chan files.Path
+	cancel context.CancelFunc
+
+	lock sync.Mutex
+	err  error
+}
+
+// Paths returns the channel the paths are sent over.
+func (s *Stream) Paths() <-chan files.Path {
+	return s.paths
+}
+
+// Cancel stops the iteration and waits for it to finish. No paths are
+// sent after it returns. It is safe to call more than once, and it
+// doesn't need to be called if the iteration is allowed to finish.
+func (s *Stream) Cancel() {
+	s.cancel()
+	for range s.paths {
+		// Discard anything that was in flight.
+	}
+}
+
+// Err returns the error that stopped the iteration, if there was one.
+// Cancelling the Stream isn't an error, but cancelling the context it
+// was started with is. It should be called after the channel has been
+// closed.
+func (s *Stream) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+func (s *Stream) fail(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err
+}
 
 // callbackIterator is a helper that provides a simple way to
 // implement the Iterator interface. The callback will be
 // called repeatedly to fetch file paths until either its second
-// return parameter is false, it returns an error, or the close function
-// is called. The close function is appropriate as a return value for
-// the Iterate method itself.
+// return parameter is false, it returns an error, or the iteration
+// is cancelled. The callback is given the context of the iteration,
+// so that it can stop waiting if it is cancelled. The iterate method
+// is appropriate as an Iterator.
 type callbackIterator struct {
-	callback func() (files.Path, bool, error)
-	cutoff   chan interface{}
+	callback func(ctx context.Context) (files.Path, bool, error)
 }
 
-func (i *callbackIterator) iterate() (<-chan files.Path, func(), error) {
-	i.cutoff = make(chan interface{})
+func (i *callbackIterator) iterate(parent context.Context) (*Stream, error) {
+	ctx, cancel := context.WithCancel(parent)
 	dest := make(chan files.Path)
+	s := &Stream{paths: dest, cancel: cancel}
 
-	// Read files into a channel so that we can select over the
-	// inputs and the retries
 	go func() {
-		for {
+		defer close(dest)
+		defer cancel()
+
+		for ctx.Err() == nil {
+			inPath, more, err := i.callback(ctx)
+			if err != nil {
+				s.fail(err)
+				return
+			}
+			if !more {
+				break
+			}
+
 			select {
-			case _, more := <-i.cutoff:
-				if !more {
-					close(dest)
-					i.cutoff = nil
-					return
-				}
-			default:
-				inPath, more, err := i.callback()
-				if err != nil {
-					slog.Error("iterator callback error: %v", err)
-					i.close()
-					continue
-				}
-				if !more {
-					i.close()
-					continue
-				}
-				dest <- inPath
+			case dest <- inPath:
+			case <-ctx.Done():
 			}
 		}
-	}()
 
-	return dest, i.close, nil
-}
+		// Only the caller's context being cancelled is an error, the
+		// Stream being cancelled means the caller is done with it.
+		if err := parent.Err(); err != nil {
+			s.fail(err)
+		}
+	}()
 
-func (i *callbackIterator) close() {
-	close(i.cutoff)
+	return s, nil
 }
@@ -1,6 +1,8 @@
 package inputs
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/alecthomas/assert/v2"
@@ -10,9 +12,9 @@ import (
 
 var paths = []files.Path{"foo", "bar", "baz"}
 
-func getCallback() func() (files.Path, bool, error) {
+func getCallback() func(context.Context) (files.Path, bool, error) {
 	next := 0
-	return func() (files.Path, bool, error) {
+	return func(context.Context) (files.Path, bool, error) {
 		if next >= len(paths) {
 			return "", false, nil
 		}
@@ -24,8 +26,10 @@ func getCallback() func() (files.Path, bool, error) {
 
 func Test_callbackIterator_iterate(t *testing.T) {
 	cbi := callbackIterator{callback: getCallback()}
-	pc, _, _ := cbi.iterate()
+	s, err := cbi.iterate(context.Background())
+	assert.NoError(t, err)
 
+	pc := s.Paths()
 	assert.Equal(t, "foo", <-pc)
 	assert.Equal(t, "bar", <-pc)
 	assert.Equal(t, "baz", <-pc)
@@ -33,17 +37,62 @@ func Test_callbackIterator_iterate(t *testing.T) {
 	p, more := <-pc
 	assert.Equal(t, "", p)
 	assert.False(t, more)
+	assert.NoError(t, s.Err())
 }
 
-func Test_callbackIterator_close(t *testing.T) {
+func Test_callbackIterator_cancel(t *testing.T) {
 	cbi := callbackIterator{callback: getCallback()}
-	pc, _, _ := cbi.iterate()
+	s, err := cbi.iterate(context.Background())
+	assert.NoError(t, err)
 
+	pc := s.Paths()
 	assert.Equal(t, "foo", <-pc)
-	cbi.close()
-	assert.Equal(t, "bar", <-pc)
+	s.Cancel()
 
 	p, more := <-pc
 	assert.Equal(t, "", p)
 	assert.False(t, more)
+	assert.NoError(t, s.Err())
+
+	t.Run("should allow cancelling again", func(t *testing.T) {
+		s.Cancel()
+	})
+}
+
+func Test_callbackIterator_error(t *testing.T) {
+	failure := errors.New("listing failed")
+	next := getCallback()
+	cbi := callbackIterator{callback: func(ctx context.Context) (files.Path, bool, error) {
+		p, more, err := next(ctx)
+		if p == "bar" {
+			return "", false, failure
+		}
+		return p, more, err
+	}}
+	s, err := cbi.iterate(context.Background())
+	assert.NoError(t, err)
+
+	var got []files.Path
+	for p := range s.Paths() {
+		got = append(got, p)
+	}
+	assert.Equal(t, []files.Path{"foo"}, got)
+	assert.True(t, errors.Is(s.Err(), failure))
+}
+
+func Test_callbackIterator_context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cbi := callbackIterator{callback: getCallback()}
+	s, err := cbi.iterate(ctx)
+	assert.NoError(t, err)
+
+	pc := s.Paths()
+	assert.Equal(t, "foo", <-pc)
+	cancel()
+
+	for range pc {
+		// A path may have been in flight when the context was
+		// cancelled.
+	}
+	assert.True(t, errors.Is(s.Err(), context.Canceled))
 }
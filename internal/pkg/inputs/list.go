@@ -0,0 +1,54 @@
+package inputs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// List provides an iterator over the files in a directory, in any
+// store that can list files (see files.Lister), such as a GCS bucket.
+// Like Local, it doesn't look in subdirectories. The directory is
+// listed once, up front, so files added later are not provided (see
+// Watch for that), and each iteration provides the same files.
+func List(l files.Lister, dir files.Dir, filters ...Filter) (Iterator, error) {
+	infos, err := l.List(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inputs (%s): %w", dir, err)
+	}
+
+	var paths []files.Path
+	for _, info := range infos {
+		if accept(info, filters) {
+			paths = append(paths, info.Path)
+		}
+	}
+
+	return func(ctx context.Context) (*Stream, error) {
+		index := 0
+		cbi := &callbackIterator{
+			callback: func(context.Context) (files.Path, bool, error) {
+				if index >= len(paths) {
+					return "", false, nil
+				}
+				p := paths[index]
+				index++
+				return p, true, nil
+			},
+		}
+
+		return cbi.iterate(ctx)
+	}, nil
+}
+
+// accept indicates whether the file passes all the filters.
+func accept(info files.Info, filters []Filter) bool {
+	for _, f := range filters {
+		if !f(info) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,43 @@
+package inputs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestList(t *testing.T) {
+	root := makeTree(t, "b.txt", "a.txt", "c.csv", "sub/d.txt")
+
+	t.Run("should provide the files in the directory", func(t *testing.T) {
+		iter, err := List(&files.Local{}, files.Dir(root), WithExt("txt"))
+		assert.NoError(t, err)
+
+		s, err := iter(context.Background())
+		assert.NoError(t, err)
+
+		var found []string
+		for p := range s.Paths() {
+			found = append(found, p.File())
+		}
+		assert.NoError(t, s.Err())
+		assert.Equal(t, []string{"a.txt", "b.txt"}, found)
+	})
+
+	t.Run("should provide the files again on every iteration", func(t *testing.T) {
+		iter, err := List(&files.Local{}, files.Dir(root), WithExt("txt"))
+		assert.NoError(t, err)
+
+		first := relPaths(t, root, iter)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, first)
+		assert.Equal(t, first, relPaths(t, root, iter))
+	})
+
+	t.Run("should error on a missing directory", func(t *testing.T) {
+		_, err := List(&files.Local{}, files.Dir(root).SubDir("missing"))
+		assert.Error(t, err)
+	})
+}
@@ -1,44 +1,58 @@
 package inputs
 
 import (
+	"context"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
 )
 
 func TestLocal(t *testing.T) {
+	dir, err := filepath.Abs("fixtures")
+	assert.NoError(t, err)
+
+	collect := func(t *testing.T, iter Iterator) []files.Path {
+		s, err := iter(context.Background())
+		assert.NoError(t, err)
+
+		var found []files.Path
+		for p := range s.Paths() {
+			rel, err := filepath.Rel(dir, string(p))
+			assert.NoError(t, err)
+			found = append(found, files.Path(rel))
+		}
+		assert.NoError(t, s.Err())
+
+		return found
+	}
+
 	t.Run("unfiltered", func(t *testing.T) {
-		// iter, err := Local(files.Dir("fixtures"))
-		// assert.NoError(t, err)
-		//
-		// for _, tc := range []struct {
-		// 	value files.Path
-		// 	ok    bool
-		// }{
-		// 	{"fixtures/file0.txt", true},
-		// 	{"fixtures/file1.txt", true},
-		// 	{"fixtures/file2.txt", true},
-		// 	{"fixtures/file3.txt", true},
-		// 	{"", false},
-		// } {
-		// 	//
-		// }
+		iter, err := Local(files.Dir(dir))
+		assert.NoError(t, err)
+
+		assert.Equal(t, []files.Path{
+			"file0.txt",
+			"file1.txt",
+			"file2.txt",
+			"file3.txt",
+		}, collect(t, iter))
 	})
 
 	t.Run("filtered", func(t *testing.T) {
-		// iter, err := Local("fixtures", func(p files.Path) bool {
-		// 	return strings.Contains(string(p), "1")
-		// })
-		// assert.NoError(t, err)
-		//
-		// for _, tc := range []struct {
-		// 	value files.Path
-		// 	ok    bool
-		// }{
-		// 	{"fixtures/file1.txt", true},
-		// 	{"", false},
-		// } {
-		// 	value, ok := iter()
-		// 	assert.Equal(t, tc.value, value)
-		// 	assert.Equal(t, tc.ok, ok)
-		// }
+		iter, err := Local(files.Dir(dir), func(i files.Info) bool {
+			return strings.Contains(string(i.Path), "1")
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []files.Path{"file1.txt"}, collect(t, iter))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := Local(files.Dir(filepath.Join(dir, "missing")))
+		assert.Error(t, err)
 	})
 }
@@ -0,0 +1,384 @@
+package inputs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// ManifestFormat is the format of a manifest file.
+type ManifestFormat string
+
+const (
+	// ManifestLines is a list of paths, one per line. Blank lines, and
+	// lines starting with #, are ignored.
+	ManifestLines ManifestFormat = "lines"
+
+	// ManifestCSV is a CSV file with a header row. One column holds
+	// the paths, the rest are metadata.
+	ManifestCSV ManifestFormat = "csv"
+
+	// ManifestTSV is like ManifestCSV, but separated by tabs.
+	ManifestTSV ManifestFormat = "tsv"
+
+	// ManifestJSONL is a file with a JSON object on each line. One
+	// field holds the path, the rest are metadata.
+	ManifestJSONL ManifestFormat = "jsonl"
+)
+
+// ManifestFormatOf returns the format of the manifest at the given
+// path, based on its extension. Anything unrecognized is assumed to
+// be a list of paths.
+func ManifestFormatOf(p files.Path) ManifestFormat {
+	switch path.Ext(p.File()) {
+	case ".csv":
+		return ManifestCSV
+	case ".tsv":
+		return ManifestTSV
+	case ".jsonl", ".ndjson":
+		return ManifestJSONL
+	}
+
+	return ManifestLines
+}
+
+// Manifest is a list of inputs, along with any metadata that goes with
+// them, read from a file, such as a sample sheet. The inputs may be in
+// any store, relative paths are relative to the manifest itself.
+type Manifest struct {
+	format  ManifestFormat
+	column  string
+	filters []Filter
+
+	paths   []files.Path
+	columns []string
+	meta    map[files.Path]map[string]string
+}
+
+// NewManifest reads the manifest at the given path from the store. By
+// default, the format is determined by the extension (see
+// ManifestFormatOf) and paths are read from the "path" column. Every
+// input must be accepted by the store. Inputs that don't pass the
+// filters, if any, are left out (see WithManifestFilters).
+func NewManifest(s files.Store, p files.Path, opts ...option.Func[*Manifest]) (*Manifest, error) {
+	m := &Manifest{
+		format: ManifestFormatOf(p),
+		column: "path",
+		meta:   map[files.Path]map[string]string{},
+	}
+
+	err := option.Apply(m, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("NewManifest: failed to apply options: %w", err)
+	}
+
+	r, err := s.Load(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest (%s): %w", p, err)
+	}
+	defer func() { _ = r.Close() }()
+
+	switch m.format {
+	case ManifestLines:
+		err = m.readLines(r)
+	case ManifestCSV:
+		err = m.readCSV(r, ',')
+	case ManifestTSV:
+		err = m.readCSV(r, '\t')
+	case ManifestJSONL:
+		err = m.readJSONL(r)
+	default:
+		err = fmt.Errorf("unknown format (%s)", m.format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest (%s): %w", p, err)
+	}
+
+	var errs []error
+	seen := map[files.Path]bool{}
+	for i, in := range m.paths {
+		resolved, err := resolvePath(p, in)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !s.Accepts(resolved) {
+			errs = append(errs, fmt.Errorf("unsupported input (%s)", resolved))
+			continue
+		}
+		if seen[resolved] {
+			errs = append(errs, fmt.Errorf("duplicate input (%s)", resolved))
+			continue
+		}
+		seen[resolved] = true
+
+		if meta, ok := m.meta[in]; ok {
+			delete(m.meta, in)
+			m.meta[resolved] = meta
+		}
+		m.paths[i] = resolved
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid manifest (%s): %w", p, errors.Join(errs...))
+	}
+
+	if len(m.filters) > 0 {
+		err = m.filter(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest (%s): %w", p, err)
+		}
+	}
+
+	return m, nil
+}
+
+// WithManifestFormat overrides the format implied by the extension of
+// the manifest.
+func WithManifestFormat(f ManifestFormat) option.Func[*Manifest] {
+	return func(m *Manifest) error {
+		m.format = f
+		return nil
+	}
+}
+
+// WithColumn sets the CSV column, or JSONL field, that holds the input
+// paths.
+func WithColumn(name string) option.Func[*Manifest] {
+	return func(m *Manifest) error {
+		if name == "" {
+			return fmt.Errorf("column name is required")
+		}
+		m.column = name
+		return nil
+	}
+}
+
+// WithManifestFilters only provides inputs that pass all the filters.
+// Each input is described by the store (see files.StatOf), so filters
+// on size or modification time only pass inputs in stores that know
+// them.
+func WithManifestFilters(filters ...Filter) option.Func[*Manifest] {
+	return func(m *Manifest) error {
+		m.filters = append(m.filters, filters...)
+		return nil
+	}
+}
+
+// Iterate provides the inputs, in the order they appear in the
+// manifest. It is an Iterator.
+func (m *Manifest) Iterate(ctx context.Context) (*Stream, error) {
+	index := 0
+	cbi := &callbackIterator{
+		callback: func(context.Context) (files.Path, bool, error) {
+			if index >= len(m.paths) {
+				return "", false, nil
+			}
+			p := m.paths[index]
+			index++
+			return p, true, nil
+		},
+	}
+
+	return cbi.iterate(ctx)
+}
+
+// Meta returns the metadata that goes with the given input, which may
+// be empty, or nil if the input isn't in the manifest.
+func (m *Manifest) Meta(p files.Path) map[string]string {
+	return m.meta[p]
+}
+
+// Columns returns the names of the metadata columns, in sorted order.
+// Every input has a value for each of them, although it may be empty.
+func (m *Manifest) Columns() []string {
+	return m.columns
+}
+
+// filter leaves out the inputs, and their metadata, that don't pass
+// the filters.
+func (m *Manifest) filter(s files.Store) error {
+	var errs []error
+	kept := m.paths[:0]
+	for _, p := range m.paths {
+		info, err := files.StatOf(s, p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to describe input (%s): %w", p, err))
+			continue
+		}
+		if !accept(info, m.filters) {
+			delete(m.meta, p)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	m.paths = kept
+
+	return errors.Join(errs...)
+}
+
+func (m *Manifest) readLines(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m.paths = append(m.paths, files.Path(line))
+	}
+
+	return scanner.Err()
+}
+
+func (m *Manifest) readCSV(r io.Reader, sep rune) error {
+	cr := csv.NewReader(r)
+	cr.Comma = sep
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	col := -1
+	for i, name := range header {
+		if name == m.column {
+			col = i
+			continue
+		}
+		m.columns = append(m.columns, name)
+	}
+	if col < 0 {
+		return fmt.Errorf("no %s column", m.column)
+	}
+	sort.Strings(m.columns)
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		p := files.Path(strings.TrimSpace(row[col]))
+		if p == "" {
+			return fmt.Errorf("line %d: %s is empty", line, m.column)
+		}
+
+		meta := map[string]string{}
+		for i, value := range row {
+			if i != col {
+				meta[header[i]] = value
+			}
+		}
+
+		m.paths = append(m.paths, p)
+		m.meta[p] = meta
+	}
+}
+
+func (m *Manifest) readJSONL(r io.Reader) error {
+	columns := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var row map[string]json.RawMessage
+		err := json.Unmarshal(text, &row)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var p files.Path
+		err = json.Unmarshal(row[m.column], &p)
+		if err != nil || p == "" {
+			return fmt.Errorf("line %d: %s must be a non-empty string", line, m.column)
+		}
+
+		meta := map[string]string{}
+		for name, raw := range row {
+			if name == m.column {
+				continue
+			}
+			columns[name] = true
+
+			var s string
+			if json.Unmarshal(raw, &s) != nil {
+				// Anything other than a string is kept as JSON.
+				s = string(raw)
+			}
+			meta[name] = s
+		}
+
+		m.paths = append(m.paths, p)
+		m.meta[p] = meta
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for name := range columns {
+		m.columns = append(m.columns, name)
+	}
+	sort.Strings(m.columns)
+
+	// Rows may leave out fields, but every input should have a value
+	// for every column, so templates work for all of them.
+	for _, meta := range m.meta {
+		for _, name := range m.columns {
+			if _, ok := meta[name]; !ok {
+				meta[name] = ""
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolvePath returns the location of an input listed in the manifest
+// at base. Paths with a scheme, and absolute paths, are used as they
+// are, anything else is relative to the manifest.
+func resolvePath(base, p files.Path) (files.Path, error) {
+	u, err := url.Parse(string(p))
+	if err == nil && u.Scheme != "" {
+		return p, nil
+	}
+
+	if path.IsAbs(string(p)) {
+		return p, nil
+	}
+
+	b, err := url.Parse(string(base))
+	if err == nil && b.Scheme != "" {
+		rel, err := url.Parse(string(p))
+		if err != nil {
+			return "", fmt.Errorf("invalid input (%s): %w", p, err)
+		}
+		return files.Path(b.ResolveReference(rel).String()), nil
+	}
+
+	return base.Dir().PathTo(string(p)), nil
+}
@@ -0,0 +1,157 @@
+package inputs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// manifestStore accepts local paths and HTTP URLs, without fetching
+// anything from the network.
+func manifestStore(t *testing.T) files.Store {
+	t.Helper()
+
+	h, err := files.NewHttp()
+	assert.NoError(t, err)
+
+	m, err := files.NewMulti(files.WithStore(&files.Local{}), files.WithStore(h))
+	assert.NoError(t, err)
+
+	return m
+}
+
+// loadManifest loads one of the manifest fixtures and returns it,
+// along with the inputs it lists, made relative to the fixtures
+// directory when they are local.
+func loadManifest(t *testing.T, s files.Store, name string, opts ...option.Func[*Manifest]) (*Manifest, []string) {
+	t.Helper()
+
+	abs, err := filepath.Abs(filepath.Join("fixtures", "manifests", name))
+	assert.NoError(t, err)
+
+	m, err := NewManifest(s, files.Path(abs), opts...)
+	assert.NoError(t, err)
+
+	stream, err := m.Iterate(context.Background())
+	assert.NoError(t, err)
+
+	fixtures := filepath.Dir(filepath.Dir(abs))
+	var paths []string
+	for p := range stream.Paths() {
+		paths = append(paths, strings.TrimPrefix(string(p), fixtures+"/"))
+	}
+
+	return m, paths
+}
+
+func TestManifest(t *testing.T) {
+	s := manifestStore(t)
+	want := []string{"file0.txt", "/data/file1.txt", "https://example.com/file2.txt"}
+
+	t.Run("should read a list of paths", func(t *testing.T) {
+		m, paths := loadManifest(t, s, "samples.txt")
+		assert.Equal(t, want, paths)
+		assert.Equal(t, nil, m.Columns())
+	})
+
+	t.Run("should read a csv file", func(t *testing.T) {
+		m, paths := loadManifest(t, s, "samples.csv")
+		assert.Equal(t, want, paths)
+		assert.Equal(t, []string{"condition", "sample"}, m.Columns())
+		assert.Equal(t, map[string]string{
+			"sample":    "s1",
+			"condition": "treated, 2h",
+		}, m.Meta("/data/file1.txt"))
+	})
+
+	t.Run("should read a tsv file with another column", func(t *testing.T) {
+		m, paths := loadManifest(t, s, "samples.tsv", WithColumn("file"))
+		assert.Equal(t, want[:2], paths)
+		assert.Equal(t, []string{"condition", "sample"}, m.Columns())
+	})
+
+	t.Run("should read a jsonl file", func(t *testing.T) {
+		m, paths := loadManifest(t, s, "samples.jsonl")
+		assert.Equal(t, want, paths)
+		assert.Equal(t, []string{"paired", "reads", "sample"}, m.Columns())
+		assert.Equal(t, map[string]string{
+			"sample": "s1",
+			"paired": "true",
+			"reads":  "",
+		}, m.Meta("/data/file1.txt"))
+	})
+
+	t.Run("should reject a missing column", func(t *testing.T) {
+		abs, err := filepath.Abs("fixtures/manifests/samples.csv")
+		assert.NoError(t, err)
+
+		_, err = NewManifest(s, files.Path(abs), WithColumn("file"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no file column")
+	})
+
+	t.Run("should reject inputs the store doesn't support", func(t *testing.T) {
+		abs, err := filepath.Abs("fixtures/manifests/samples.csv")
+		assert.NoError(t, err)
+
+		_, err = NewManifest(&files.Local{}, files.Path(abs))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported input (https://example.com/file2.txt)")
+	})
+}
+
+func TestWithManifestFilters(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.fastq", "c.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+	manifest := filepath.Join(dir, "samples.csv")
+	assert.NoError(t, os.WriteFile(manifest, []byte("path,sample\na.txt,s0\nb.fastq,s1\nc.txt,s2\n"), 0644))
+
+	t.Run("should leave out inputs that don't pass the filters", func(t *testing.T) {
+		m, err := NewManifest(&files.Local{}, files.Path(manifest), WithManifestFilters(WithExt("txt")))
+		assert.NoError(t, err)
+
+		stream, err := m.Iterate(context.Background())
+		assert.NoError(t, err)
+
+		var paths []files.Path
+		for p := range stream.Paths() {
+			paths = append(paths, p)
+		}
+		a := files.Path(filepath.Join(dir, "a.txt"))
+		b := files.Path(filepath.Join(dir, "b.fastq"))
+		c := files.Path(filepath.Join(dir, "c.txt"))
+		assert.Equal(t, []files.Path{a, c}, paths)
+		assert.Equal(t, map[string]string{"sample": "s2"}, m.Meta(c))
+		assert.Equal(t, nil, m.Meta(b))
+	})
+
+	t.Run("should reject inputs that can't be described", func(t *testing.T) {
+		assert.NoError(t, os.Remove(filepath.Join(dir, "c.txt")))
+
+		_, err := NewManifest(&files.Local{}, files.Path(manifest), WithManifestFilters(WithExt("txt")))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to describe input")
+	})
+}
+
+func TestManifestFormatOf(t *testing.T) {
+	for p, f := range map[files.Path]ManifestFormat{
+		"/a/samples.csv":            ManifestCSV,
+		"/a/samples.tsv":            ManifestTSV,
+		"gs://b/samples.jsonl":      ManifestJSONL,
+		"https://a.com/rows.ndjson": ManifestJSONL,
+		"/a/samples.txt":            ManifestLines,
+		"/a/samples":                ManifestLines,
+	} {
+		assert.Equal(t, f, ManifestFormatOf(p), "%s", p)
+	}
+}
@@ -0,0 +1,307 @@
+package inputs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// SymlinkPolicy determines which symbolic links a Walker follows.
+type SymlinkPolicy string
+
+const (
+	// SymlinksSkip ignores symbolic links entirely.
+	SymlinksSkip SymlinkPolicy = "skip"
+
+	// SymlinksFiles follows links to files, but not to directories.
+	SymlinksFiles SymlinkPolicy = "files"
+
+	// SymlinksFollow follows links to files and to directories. Each
+	// directory is only walked once, so cycles are harmless.
+	SymlinksFollow SymlinkPolicy = "follow"
+)
+
+// ParseSymlinkPolicy converts a policy name, as given on the command
+// line, into a SymlinkPolicy.
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(s); p {
+	case SymlinksSkip, SymlinksFiles, SymlinksFollow:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid symlink policy (%s)", s)
+}
+
+// Walker finds the normal files in a directory and, optionally, its
+// subdirectories. Directories are read as they are reached, so only
+// the directories on the path to the current file are held in memory.
+type Walker struct {
+	maxDepth int
+	pattern  string
+	hidden   bool
+	symlinks SymlinkPolicy
+	filters  []Filter
+}
+
+// walkState is the state of a single walk of a directory by a Walker.
+type walkState struct {
+	*Walker
+
+	stack []*walkDir
+	seen  map[string]bool
+}
+
+// walkDir is a directory that is being walked.
+type walkDir struct {
+	dir     files.Dir
+	rel     string
+	depth   int
+	entries []fs.DirEntry
+}
+
+// Walk provides an iterator over the files found by a Walker. By
+// default, it finds the files in the directory itself, skipping hidden
+// files and following symbolic links to files. Each iteration walks
+// the directory again, from the start.
+func Walk(dir files.Dir, opts ...option.Func[*Walker]) (Iterator, error) {
+	w := &Walker{
+		maxDepth: 1,
+		symlinks: SymlinksFiles,
+	}
+
+	err := option.Apply(w, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Walk: failed to apply options: %w", err)
+	}
+
+	// Make sure the directory can be walked before it is needed.
+	_, err = w.start(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (*Stream, error) {
+		wk, err := w.start(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		cbi := &callbackIterator{callback: wk.next}
+
+		return cbi.iterate(ctx)
+	}, nil
+}
+
+// start begins a walk of the directory.
+func (w *Walker) start(dir files.Dir) (*walkState, error) {
+	entries, err := os.ReadDir(string(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local inputs (%s): %w", dir, err)
+	}
+
+	wk := &walkState{Walker: w, seen: map[string]bool{}}
+	wk.visited(dir)
+	wk.stack = append(wk.stack, &walkDir{dir: dir, depth: 1, entries: entries})
+
+	return wk, nil
+}
+
+// WithMaxDepth sets how many levels of directories are walked. The
+// default, 1, means only the files in the directory itself, and 0
+// means there is no limit.
+func WithMaxDepth(depth int) option.Func[*Walker] {
+	return func(w *Walker) error {
+		if depth < 0 {
+			return fmt.Errorf("max depth must not be negative")
+		}
+		w.maxDepth = depth
+		return nil
+	}
+}
+
+// WithPattern only finds files whose paths, relative to the directory
+// being walked, match the pattern. Patterns may use "**" to match any
+// number of directories, like "**/*.fastq.gz".
+func WithPattern(pattern string) option.Func[*Walker] {
+	return func(w *Walker) error {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid pattern (%s)", pattern)
+		}
+		w.pattern = pattern
+		return nil
+	}
+}
+
+// WithHidden finds hidden files, and walks hidden directories, which
+// are those whose names start with a dot.
+func WithHidden() option.Func[*Walker] {
+	return func(w *Walker) error {
+		w.hidden = true
+		return nil
+	}
+}
+
+// WithSymlinks sets the SymlinkPolicy.
+func WithSymlinks(p SymlinkPolicy) option.Func[*Walker] {
+	return func(w *Walker) error {
+		_, err := ParseSymlinkPolicy(string(p))
+		if err != nil {
+			return err
+		}
+		w.symlinks = p
+		return nil
+	}
+}
+
+// WithFilters only finds files that pass all the filters.
+func WithFilters(filters ...Filter) option.Func[*Walker] {
+	return func(w *Walker) error {
+		w.filters = append(w.filters, filters...)
+		return nil
+	}
+}
+
+// next returns the next file that should be used, reading directories
+// as they are reached.
+func (w *walkState) next(context.Context) (files.Path, bool, error) {
+	for len(w.stack) > 0 {
+		top := w.stack[len(w.stack)-1]
+		if len(top.entries) == 0 {
+			w.stack = w.stack[:len(w.stack)-1]
+			continue
+		}
+
+		e := top.entries[0]
+		top.entries = top.entries[1:]
+
+		name := e.Name()
+		if !w.hidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		p := top.dir.PathTo(name)
+		rel := path.Join(top.rel, name)
+
+		mode := e.Type()
+		if mode&fs.ModeSymlink != 0 {
+			if w.symlinks == SymlinksSkip {
+				continue
+			}
+
+			info, err := os.Stat(string(p))
+			if err != nil {
+				slog.Warn("skipping broken symlink", "path", p, "error", err)
+				continue
+			}
+
+			mode = info.Mode().Type()
+			if mode.IsDir() && w.symlinks != SymlinksFollow {
+				continue
+			}
+		}
+
+		if mode.IsDir() {
+			w.descend(files.Dir(p), rel, top.depth+1)
+			continue
+		}
+
+		if !mode.IsRegular() {
+			continue
+		}
+
+		if w.pattern != "" && !doublestar.MatchUnvalidated(w.pattern, rel) {
+			continue
+		}
+
+		ok, err := w.filter(p)
+		if err != nil {
+			return "", false, err
+		}
+		if ok {
+			return p, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// descend starts walking a subdirectory, unless it is too deep or has
+// been walked already.
+func (w *walkState) descend(dir files.Dir, rel string, depth int) {
+	if w.maxDepth > 0 && depth > w.maxDepth {
+		return
+	}
+
+	if !w.visited(dir) {
+		return
+	}
+
+	entries, err := os.ReadDir(string(dir))
+	if err != nil {
+		slog.Warn("skipping unreadable input directory", "path", dir, "error", err)
+		return
+	}
+
+	w.stack = append(w.stack, &walkDir{dir: dir, rel: rel, depth: depth, entries: entries})
+}
+
+// visited records that a directory is being walked, and indicates
+// whether it is the first time. Only links to directories can lead to
+// the same directory twice, so the check is skipped otherwise.
+func (w *walkState) visited(dir files.Dir) bool {
+	if w.symlinks != SymlinksFollow {
+		return true
+	}
+
+	resolved, err := filepath.EvalSymlinks(string(dir))
+	if err != nil {
+		resolved = string(dir)
+	}
+
+	if w.seen[resolved] {
+		return false
+	}
+	w.seen[resolved] = true
+
+	return true
+}
+
+// filter applies the filters to the file, if there are any.
+func (w *walkState) filter(p files.Path) (bool, error) {
+	if len(w.filters) == 0 {
+		return true, nil
+	}
+
+	info, err := os.Stat(string(p))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// The file was removed after its directory was read.
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get local input info (%s): %w", p, err)
+	}
+
+	fi := files.Info{
+		Path:    p,
+		Size:    files.Size(info.Size()),
+		ModTime: info.ModTime(),
+	}
+
+	for _, f := range w.filters {
+		if !f(fi) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
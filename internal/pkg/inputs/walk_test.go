@@ -0,0 +1,171 @@
+package inputs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// makeTree creates the given files, and any directories they need,
+// under a temporary directory, which it returns.
+func makeTree(t *testing.T, names ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, name := range names {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, os.WriteFile(p, []byte(name), 0644))
+	}
+
+	return root
+}
+
+// walk collects the paths, relative to root, found by a Walker.
+func walk(t *testing.T, root string, opts ...option.Func[*Walker]) []string {
+	t.Helper()
+
+	iter, err := Walk(files.Dir(root), opts...)
+	assert.NoError(t, err)
+
+	return relPaths(t, root, iter)
+}
+
+// relPaths runs the iterator and collects the paths it provides,
+// relative to root.
+func relPaths(t *testing.T, root string, iter Iterator) []string {
+	t.Helper()
+
+	s, err := iter(context.Background())
+	assert.NoError(t, err)
+
+	var found []string
+	for p := range s.Paths() {
+		rel, err := filepath.Rel(root, string(p))
+		assert.NoError(t, err)
+		found = append(found, filepath.ToSlash(rel))
+	}
+	assert.NoError(t, s.Err())
+
+	return found
+}
+
+func TestWalk(t *testing.T) {
+	root := makeTree(t,
+		"a.fastq.gz",
+		"b.txt",
+		".hidden.txt",
+		"one/c.fastq.gz",
+		"one/two/d.fastq.gz",
+		".git/e.fastq.gz",
+	)
+
+	t.Run("should only find files in the directory by default", func(t *testing.T) {
+		assert.Equal(t, []string{"a.fastq.gz", "b.txt"}, walk(t, root))
+	})
+
+	t.Run("should walk subdirectories", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"a.fastq.gz",
+			"b.txt",
+			"one/c.fastq.gz",
+			"one/two/d.fastq.gz",
+		}, walk(t, root, WithMaxDepth(0)))
+	})
+
+	t.Run("should stop at the max depth", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"a.fastq.gz",
+			"b.txt",
+			"one/c.fastq.gz",
+		}, walk(t, root, WithMaxDepth(2)))
+	})
+
+	t.Run("should match patterns against relative paths", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"a.fastq.gz",
+			"one/c.fastq.gz",
+			"one/two/d.fastq.gz",
+		}, walk(t, root, WithMaxDepth(0), WithPattern("**/*.fastq.gz")))
+
+		assert.Equal(t, []string{
+			"one/c.fastq.gz",
+		}, walk(t, root, WithMaxDepth(0), WithPattern("one/*.fastq.gz")))
+	})
+
+	t.Run("should find hidden files when asked", func(t *testing.T) {
+		assert.Equal(t, []string{
+			".git/e.fastq.gz",
+			".hidden.txt",
+			"a.fastq.gz",
+			"b.txt",
+			"one/c.fastq.gz",
+			"one/two/d.fastq.gz",
+		}, walk(t, root, WithMaxDepth(0), WithHidden()))
+	})
+
+	t.Run("should apply filters", func(t *testing.T) {
+		assert.Equal(t, []string{"b.txt"}, walk(t, root, WithFilters(WithExt("txt"))))
+	})
+
+	t.Run("should walk again on every iteration", func(t *testing.T) {
+		iter, err := Walk(files.Dir(root), WithMaxDepth(0))
+		assert.NoError(t, err)
+
+		first := relPaths(t, root, iter)
+		assert.Equal(t, 4, len(first))
+		assert.Equal(t, first, relPaths(t, root, iter))
+	})
+
+	t.Run("should reject invalid options", func(t *testing.T) {
+		_, err := Walk(files.Dir(root), WithPattern("[a"))
+		assert.Error(t, err)
+
+		_, err = Walk(files.Dir(root), WithSymlinks("sometimes"))
+		assert.Error(t, err)
+	})
+}
+
+func TestWalk_symlinks(t *testing.T) {
+	root := makeTree(t, "dir/a.txt", "b.txt")
+	outside := makeTree(t, "c.txt")
+	for link, target := range map[string]string{
+		"link.txt": "b.txt",
+		"linkdir":  "dir",
+		"dir/loop": ".",
+		"outside":  outside,
+		"broken":   "missing.txt",
+	} {
+		assert.NoError(t, os.Symlink(target, filepath.Join(root, link)))
+	}
+
+	t.Run("should skip links", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"b.txt",
+			"dir/a.txt",
+		}, walk(t, root, WithMaxDepth(0), WithSymlinks(SymlinksSkip)))
+	})
+
+	t.Run("should follow links to files", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"b.txt",
+			"dir/a.txt",
+			"link.txt",
+		}, walk(t, root, WithMaxDepth(0), WithSymlinks(SymlinksFiles)))
+	})
+
+	t.Run("should follow links to directories once", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"b.txt",
+			"dir/a.txt",
+			"link.txt",
+			"outside/c.txt",
+		}, walk(t, root, WithMaxDepth(0), WithSymlinks(SymlinksFollow)))
+	})
+}
@@ -0,0 +1,249 @@
+package inputs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// Watcher polls a directory, in any store that can list files (see
+// files.Lister), for new files. Each file is provided once, after it
+// has stopped changing, so files that are still being written aren't
+// used too soon. Hidden files are ignored, unless WithWatchHidden is
+// used.
+type Watcher struct {
+	lister    files.Lister
+	dir       files.Dir
+	interval  time.Duration
+	statePath string
+	filters   []Filter
+	hidden    bool
+
+	polled  bool
+	seen    map[files.Path]bool
+	pending map[files.Path]files.Info
+	ready   []files.Path
+
+	// stateLock serializes writes to the state file, since Done is
+	// called as jobs finish.
+	stateLock sync.Mutex
+}
+
+// NewWatcher watches a directory for new files (see Iterate). By
+// default, the directory is checked every ten seconds, and the files
+// that have been provided are forgotten when the iteration ends (see
+// WithStateFile).
+func NewWatcher(l files.Lister, dir files.Dir, opts ...option.Func[*Watcher]) (*Watcher, error) {
+	w := &Watcher{
+		lister:   l,
+		dir:      dir,
+		interval: 10 * time.Second,
+		seen:     map[files.Path]bool{},
+		pending:  map[files.Path]files.Info{},
+	}
+
+	err := option.Apply(w, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Watch: failed to apply options: %w", err)
+	}
+
+	err = w.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Iterate provides the new files in the directory, and only finishes
+// when its context is cancelled. It is an Iterator.
+func (w *Watcher) Iterate(ctx context.Context) (*Stream, error) {
+	cbi := &callbackIterator{callback: w.next}
+
+	return cbi.iterate(ctx)
+}
+
+// WithPollInterval sets how often the directory is checked for new
+// files. A file is provided once it is unchanged between two checks.
+func WithPollInterval(d time.Duration) option.Func[*Watcher] {
+	return func(w *Watcher) error {
+		if d <= 0 {
+			return fmt.Errorf("poll interval must be positive")
+		}
+		w.interval = d
+		return nil
+	}
+}
+
+// WithStateFile sets a local file used to remember which files have
+// been processed, so that they aren't provided again if the watch is
+// restarted. Files are only remembered once they are passed to Done,
+// so a file whose job failed, or was interrupted, is provided again
+// after a restart.
+func WithStateFile(p string) option.Func[*Watcher] {
+	return func(w *Watcher) error {
+		w.statePath = p
+		return nil
+	}
+}
+
+// WithWatchFilters only provides files that pass all the filters.
+func WithWatchFilters(filters ...Filter) option.Func[*Watcher] {
+	return func(w *Watcher) error {
+		w.filters = append(w.filters, filters...)
+		return nil
+	}
+}
+
+// WithWatchHidden provides hidden files, whose names start with a dot,
+// as well.
+func WithWatchHidden() option.Func[*Watcher] {
+	return func(w *Watcher) error {
+		w.hidden = true
+		return nil
+	}
+}
+
+// next waits for a new file to be ready, polling the directory as
+// needed, until the context is cancelled.
+func (w *Watcher) next(ctx context.Context) (files.Path, bool, error) {
+	for {
+		if len(w.ready) > 0 {
+			p := w.ready[0]
+			w.ready = w.ready[1:]
+
+			// Don't provide the file again during this watch, even
+			// if it fails, or it would be retried forever.
+			w.seen[p] = true
+
+			return p, true, nil
+		}
+
+		if w.polled {
+			select {
+			case <-time.After(w.interval):
+			case <-ctx.Done():
+				return "", false, nil
+			}
+		}
+		w.polled = true
+
+		err := w.poll()
+		if err != nil {
+			// The directory may be briefly unavailable, so keep
+			// trying rather than giving up on the watch.
+			slog.Warn("failed to check watched inputs", "dir", w.dir, "error", err)
+		}
+	}
+}
+
+// poll lists the directory, and marks the new files that haven't
+// changed since the last poll as ready.
+func (w *Watcher) poll() error {
+	infos, err := w.lister.List(w.dir)
+	if err != nil {
+		return err
+	}
+
+	listed := map[files.Path]bool{}
+	for _, info := range infos {
+		p := info.Path
+		if w.seen[p] || (!w.hidden && strings.HasPrefix(p.File(), ".")) || !accept(info, w.filters) {
+			continue
+		}
+		listed[p] = true
+
+		prev, ok := w.pending[p]
+		if ok && prev.Size == info.Size && prev.ModTime.Equal(info.ModTime) {
+			delete(w.pending, p)
+			w.ready = append(w.ready, p)
+			continue
+		}
+		w.pending[p] = info
+	}
+
+	for p := range w.pending {
+		if !listed[p] {
+			delete(w.pending, p)
+		}
+	}
+
+	return nil
+}
+
+// loadState reads the files that have been provided before, if there
+// is a state file.
+func (w *Watcher) loadState() error {
+	if w.statePath == "" {
+		return nil
+	}
+
+	f, err := os.Open(w.statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open watch state (%s): %w", w.statePath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			w.seen[files.Path(line)] = true
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return fmt.Errorf("failed to read watch state (%s): %w", w.statePath, err)
+	}
+
+	return nil
+}
+
+// Done records that a file has been processed successfully, in the
+// state file, if there is one, so that it isn't provided again after a
+// restart. It is safe to call from any goroutine.
+func (w *Watcher) Done(p files.Path) error {
+	if w.statePath == "" {
+		return nil
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(w.statePath), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create watch state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(w.statePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open watch state (%s): %w", w.statePath, err)
+	}
+
+	_, err = fmt.Fprintln(f, p)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to update watch state (%s): %w", w.statePath, err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to update watch state (%s): %w", w.statePath, err)
+	}
+
+	return nil
+}
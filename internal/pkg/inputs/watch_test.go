@@ -0,0 +1,95 @@
+package inputs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(t.TempDir(), "state", "seen.txt")
+	write := func(name string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+
+	watch := func(ctx context.Context) (*Watcher, *Stream) {
+		w, err := NewWatcher(&files.Local{}, files.Dir(dir),
+			WithPollInterval(10*time.Millisecond),
+			WithStateFile(state),
+			WithWatchFilters(WithExt("txt")),
+		)
+		assert.NoError(t, err)
+
+		s, err := w.Iterate(ctx)
+		assert.NoError(t, err)
+		return w, s
+	}
+
+	next := func(s *Stream) string {
+		select {
+		case p := <-s.Paths():
+			return p.File()
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a new input")
+			return ""
+		}
+	}
+
+	write("a.txt")
+	write(".hidden.txt")
+	write("skip.csv")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, s := watch(ctx)
+	assert.Equal(t, "a.txt", next(s))
+	assert.NoError(t, w.Done(files.Path(filepath.Join(dir, "a.txt"))))
+
+	// The job for b.txt fails, so it is never done.
+	write("b.txt")
+	assert.Equal(t, "b.txt", next(s))
+
+	cancel()
+	for range s.Paths() {
+	}
+	assert.True(t, errors.Is(s.Err(), context.Canceled))
+
+	t.Run("should remember done inputs across restarts", func(t *testing.T) {
+		write("c.txt")
+
+		_, s := watch(context.Background())
+		defer s.Cancel()
+
+		assert.Equal(t, "b.txt", next(s))
+		assert.Equal(t, "c.txt", next(s))
+	})
+}
+
+func TestWatcher_hidden(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden.txt"), nil, 0644))
+
+	w, err := NewWatcher(&files.Local{}, files.Dir(dir),
+		WithPollInterval(10*time.Millisecond),
+		WithWatchHidden(),
+	)
+	assert.NoError(t, err)
+
+	s, err := w.Iterate(context.Background())
+	assert.NoError(t, err)
+	defer s.Cancel()
+
+	select {
+	case p := <-s.Paths():
+		assert.Equal(t, ".hidden.txt", p.File())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a new input")
+	}
+}
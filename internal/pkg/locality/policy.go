@@ -0,0 +1,107 @@
+// Package locality keeps track of where data lives, relative to where
+// tasks run, and applies a policy when running a workflow would move
+// data from one environment to another.
+package locality
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// Policy determines what happens when a file must cross from one
+// environment to another.
+type Policy string
+
+const (
+	// Allow permits all transfers, they are only logged at the debug
+	// level.
+	Allow Policy = "allow"
+
+	// Warn permits all transfers, but logs a warning, including an
+	// estimate of the amount of data moved, for each one.
+	Warn Policy = "warn"
+
+	// Deny refuses to perform transfers between environments.
+	Deny Policy = "deny"
+)
+
+// ParsePolicy converts the given string into a Policy, returning an
+// error if it is not one of the known values.
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy(strings.ToLower(strings.TrimSpace(s)))
+	switch p {
+	case Allow, Warn, Deny:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid transfer policy (%s)", s)
+	}
+}
+
+// Route describes the environments a file passes through when it
+// is moved, in order. Consecutive duplicates are collapsed, so a
+// route of length one means that the file never leaves its
+// environment.
+type Route []files.Env
+
+// NewRoute builds a route through the given environments, dropping
+// consecutive duplicates.
+func NewRoute(envs ...files.Env) Route {
+	var r Route
+	for _, e := range envs {
+		if len(r) > 0 && r[len(r)-1] == e {
+			continue
+		}
+		r = append(r, e)
+	}
+
+	return r
+}
+
+// Crossings returns the number of environment boundaries the route
+// crosses.
+func (r Route) Crossings() int {
+	if len(r) == 0 {
+		return 0
+	}
+
+	return len(r) - 1
+}
+
+// Known indicates whether every environment on the route could be
+// determined. Policies are not enforced for unknown routes.
+func (r Route) Known() bool {
+	for _, e := range r {
+		if e == files.EnvUnknown {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r Route) String() string {
+	parts := make([]string, len(r))
+	for i, e := range r {
+		if e == files.EnvUnknown {
+			parts[i] = "unknown"
+		} else {
+			parts[i] = string(e)
+		}
+	}
+
+	return strings.Join(parts, " -> ")
+}
+
+// TransferError is returned when a transfer is refused by the
+// Deny policy.
+type TransferError struct {
+	Path  files.Path
+	Route Route
+	Size  files.Size
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("transfer of %s (%s) refused by policy: %s", e.Path, e.Size, e.Route)
+}
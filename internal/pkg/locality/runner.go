@@ -0,0 +1,163 @@
+package locality
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync/atomic"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+	"github.com/glesica/flowork/internal/pkg/task"
+)
+
+// Runner wraps another task.Runner and applies a Policy to every file
+// added to, or extracted from, one of its volumes. The store is used
+// to determine the environment and size of each file outside the
+// runner.
+//
+// The optional interfaces of the wrapped runner, like task.Clearer,
+// are forwarded, so wrapping a runner doesn't hide them.
+//
+// Runners copy files through the orchestrator, so a transfer from
+// one remote environment to another (say, GCS to an SSH host) is
+// counted as crossing two boundaries.
+type Runner struct {
+	task.Runner
+
+	store  files.Store
+	policy Policy
+
+	// moved is the running total of bytes, with known sizes, that
+	// have crossed an environment boundary, counted once per crossing.
+	moved atomic.Int64
+}
+
+func NewRunner(r task.Runner, s files.Store, opts ...option.Func[*Runner]) (*Runner, error) {
+	lr := &Runner{
+		Runner: r,
+		store:  s,
+		policy: Warn,
+	}
+
+	err := option.Apply(lr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("NewRunner: failed to apply options: %w", err)
+	}
+
+	return lr, nil
+}
+
+// WithPolicy sets the policy to apply to transfers. The default is
+// Warn.
+func WithPolicy(p Policy) option.Func[*Runner] {
+	return func(r *Runner) error {
+		_, err := ParsePolicy(string(p))
+		if err != nil {
+			return err
+		}
+		r.policy = p
+		return nil
+	}
+}
+
+// Env reports the environment of the wrapped runner (see
+// task.Locator), so that wrapping a runner doesn't hide it.
+func (r *Runner) Env() files.Env {
+	return task.EnvOf(r.Runner)
+}
+
+// ClearVolume clears the volume using the wrapped runner, or returns
+// errors.ErrUnsupported if it isn't a task.Clearer.
+func (r *Runner) ClearVolume(v task.Volume) error {
+	c, ok := r.Runner.(task.Clearer)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	return c.ClearVolume(v)
+}
+
+func (r *Runner) AddFile(s files.Path, v task.Volume, name string) error {
+	route := NewRoute(files.EnvOf(r.store, s), files.EnvLocal, task.EnvOf(r.Runner))
+
+	err := r.check(s, route, func() files.Size {
+		return r.sizeOf(s)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.Runner.AddFile(s, v, name)
+}
+
+func (r *Runner) ExtractFile(s files.Path, v task.Volume, d files.Dir) error {
+	runnerEnv := task.EnvOf(r.Runner)
+	route := NewRoute(runnerEnv, files.EnvLocal, files.EnvOf(r.store, d.PathTo(s.File())))
+
+	err := r.check(s, route, func() files.Size {
+		if runnerEnv != files.EnvLocal {
+			// Remote volumes can't be sized from here.
+			return files.SizeUnknown
+		}
+		return r.sizeOf(files.Path(path.Join(string(v), s.File())))
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.Runner.ExtractFile(s, v, d)
+}
+
+// Moved returns the estimated number of bytes that have crossed an
+// environment boundary so far, counted once for each boundary.
+func (r *Runner) Moved() files.Size {
+	return files.Size(r.moved.Load())
+}
+
+func (r *Runner) check(p files.Path, route Route, size func() files.Size) error {
+	if !route.Known() {
+		slog.Debug("transfer route unknown, skipping policy", "path", p, "route", route)
+		return nil
+	}
+
+	if route.Crossings() == 0 {
+		return nil
+	}
+
+	switch r.policy {
+	case Deny:
+		s := size()
+		slog.Error("transfer refused", "path", p, "route", route, "size", s)
+		return &TransferError{Path: p, Route: route, Size: s}
+	case Warn:
+		s := size()
+		total := r.record(s, route)
+		slog.Warn("transfer crosses environments", "path", p, "route", route, "size", s, "total", total)
+	default:
+		s := size()
+		total := r.record(s, route)
+		slog.Debug("transfer crosses environments", "path", p, "route", route, "size", s, "total", total)
+	}
+
+	return nil
+}
+
+func (r *Runner) sizeOf(p files.Path) files.Size {
+	size, err := files.SizeOf(r.store, p)
+	if err != nil {
+		slog.Debug("failed to get file size", "path", p, "error", err)
+		return files.SizeUnknown
+	}
+
+	return size
+}
+
+func (r *Runner) record(size files.Size, route Route) files.Size {
+	if size < 0 {
+		return r.Moved()
+	}
+
+	return files.Size(r.moved.Add(int64(size) * int64(route.Crossings())))
+}
@@ -0,0 +1,142 @@
+package locality
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/task"
+)
+
+// fakeRunner records the files it was asked to add without
+// actually copying anything.
+type fakeRunner struct {
+	env   files.Env
+	added []files.Path
+}
+
+func (r *fakeRunner) CreateVolume(s files.Size) (task.Volume, error) { return "vol", nil }
+func (r *fakeRunner) DeleteVolume(v task.Volume) error               { return nil }
+func (r *fakeRunner) ExtractFile(s files.Path, v task.Volume, d files.Dir) error {
+	return nil
+}
+func (r *fakeRunner) LinkFile(sv task.Volume, src string, dv task.Volume, dest string) error {
+	return nil
+}
+func (r *fakeRunner) PruneVolume(v task.Volume, keep []string) error { return nil }
+func (r *fakeRunner) Run(t *task.Instance, v task.Volume) error      { return nil }
+func (r *fakeRunner) Env() files.Env                                 { return r.env }
+
+func (r *fakeRunner) AddFile(s files.Path, v task.Volume, name string) error {
+	r.added = append(r.added, s)
+	return nil
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		value  string
+		policy Policy
+		ok     bool
+	}{
+		{"allow", Allow, true},
+		{"Warn", Warn, true},
+		{" deny ", Deny, true},
+		{"sometimes", "", false},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			p, err := ParsePolicy(tc.value)
+			assert.Equal(t, tc.policy, p)
+			assert.Equal(t, tc.ok, err == nil)
+		})
+	}
+}
+
+func TestNewRoute(t *testing.T) {
+	r := NewRoute(files.EnvGcs, files.EnvLocal, task.EnvSsh)
+	assert.Equal(t, 2, r.Crossings())
+	assert.Equal(t, "gcs -> local -> ssh", r.String())
+
+	r = NewRoute(files.EnvLocal, files.EnvLocal, files.EnvLocal)
+	assert.Equal(t, 0, r.Crossings())
+
+	r = NewRoute(files.EnvUnknown, files.EnvLocal)
+	assert.False(t, r.Known())
+}
+
+func TestRunner_AddFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "data.txt")
+	err := os.WriteFile(src, []byte("abcd"), 0644)
+	assert.NoError(t, err)
+
+	t.Run("should allow a local transfer under deny", func(t *testing.T) {
+		inner := &fakeRunner{env: files.EnvLocal}
+		r, err := NewRunner(inner, &files.Local{}, WithPolicy(Deny))
+		assert.NoError(t, err)
+
+		err = r.AddFile(files.Path(src), "vol", "data.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, []files.Path{files.Path(src)}, inner.added)
+		assert.Equal(t, 0, r.Moved())
+	})
+
+	t.Run("should refuse a remote transfer under deny", func(t *testing.T) {
+		inner := &fakeRunner{env: task.EnvSsh}
+		r, err := NewRunner(inner, &files.Local{}, WithPolicy(Deny))
+		assert.NoError(t, err)
+
+		err = r.AddFile(files.Path(src), "vol", "data.txt")
+		var transferErr *TransferError
+		assert.True(t, errors.As(err, &transferErr))
+		assert.Equal(t, 4, transferErr.Size)
+		assert.Zero(t, inner.added)
+	})
+
+	t.Run("should count moved bytes under warn", func(t *testing.T) {
+		inner := &fakeRunner{env: task.EnvSsh}
+		r, err := NewRunner(inner, &files.Local{}, WithPolicy(Warn))
+		assert.NoError(t, err)
+
+		err = r.AddFile(files.Path(src), "vol", "data.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(inner.added))
+		assert.Equal(t, 4, r.Moved())
+	})
+
+	t.Run("should ignore runners with an unknown environment", func(t *testing.T) {
+		inner := &fakeRunner{env: files.EnvUnknown}
+		r, err := NewRunner(inner, &files.Local{}, WithPolicy(Deny))
+		assert.NoError(t, err)
+
+		err = r.AddFile(files.Path(src), "vol", "data.txt")
+		assert.NoError(t, err)
+	})
+}
+
+func TestRunner_Env(t *testing.T) {
+	r, err := NewRunner(&fakeRunner{env: task.EnvSsh}, &files.Local{})
+	assert.NoError(t, err)
+	assert.Equal(t, task.EnvSsh, task.EnvOf(r))
+}
+
+func TestRunner_ClearVolume(t *testing.T) {
+	inner := &task.DockerRunner{WorkDir: files.Dir(t.TempDir())}
+	r, err := NewRunner(inner, &files.Local{})
+	assert.NoError(t, err)
+
+	v, err := r.CreateVolume(0)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(string(v), "a.txt"), nil, 0644))
+
+	cleared, err := task.ClearOrReplace(r, v)
+	assert.NoError(t, err)
+	assert.Equal(t, v, cleared)
+
+	entries, err := os.ReadDir(string(v))
+	assert.NoError(t, err)
+	assert.Zero(t, entries)
+}
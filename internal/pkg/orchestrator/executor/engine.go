@@ -20,54 +20,96 @@ func NoopEngine(job *orchestrator.Job) error {
 }
 
 // SimpleEngine will generally be used to run workflows in practice.
+//
+// If the job fails, its volumes are kept (see orchestrator.Job) so
+// that the engine can resume at the failed task if the job is run
+// again. The caller is responsible for releasing the job if it is
+// not going to be retried.
 func SimpleEngine(job *orchestrator.Job) error {
-	slog.Info("executing job", "engine", "simple", "job", job.Id)
+	completed := job.Completed()
+	slog.Info("executing job", "engine", "simple", "job", job.Id, "completed", completed, "attempt", job.Attempts)
 
-	vol, err := job.Runner.CreateVolume(0)
-	if err != nil {
-		return fmt.Errorf("failed to create volume %w", err)
-	}
-
-	slog.Debug("created job volume", "engine", "simple", "job", job.Id, "volume", vol)
-
-	defer func() {
-		// TODO: Don't delete in debug mode
-		err = job.Runner.DeleteVolume(vol)
+	if completed == 0 {
+		// Nothing to resume, so we start over from scratch.
+		err := stageInputs(job)
 		if err != nil {
-			slog.Error("failed to delete volume", "engine", "simple", "error", err, "job", job.Id, "volume", vol)
-		} else {
-			slog.Debug("deleted job volume", "engine", "simple", "job", job.Id, "volume", vol)
+			return err
 		}
-	}()
+	}
 
-	firstTask := job.Tasks[0]
-	for _, input := range firstTask.Inputs {
-		err := job.Runner.AddFile(job.InPath, vol, input.File())
-		if err != nil {
-			return fmt.Errorf("simple engine: failed to copy %s to volume as %s: %w", job.InPath, input, err)
-		} else {
-			slog.Debug("copied input to volume", "engine", "simple", "job", job.Id, "volume", vol, "input", job.InPath)
-		}
+	var err error
+	if job.IsolateTasks {
+		job.Volumes, err = task.RunIsolated(job.Runner, job.Tasks, job.Volumes)
+	} else {
+		err = task.RunAll(job.Runner, job.Tasks, job.Volumes[0], job.KeepIntermediates)
+	}
+
+	if job.Completed() > completed {
+		// Progress was made, so the current task gets a fresh set of
+		// attempts, starting with this one.
+		job.Attempts = 1
 	}
 
-	err = task.RunAll(job.Runner, job.Tasks, vol)
 	if err != nil {
 		return fmt.Errorf("simple engine: failed to run tasks: %w", err)
 	}
 
-	slog.Debug("finished running tasks", "engine", "simple", "job", job.Id, "volume", vol)
+	slog.Debug("finished running tasks", "engine", "simple", "job", job.Id)
 
+	outVol := job.Volumes[len(job.Volumes)-1]
 	lastTask := job.Tasks[len(job.Tasks)-1]
 	for _, output := range lastTask.Task.Outputs {
 		dest := job.OutDir.SubDir(lastTask.ID)
 
-		err := job.Runner.ExtractFile(output, vol, dest)
+		err := job.Runner.ExtractFile(output, outVol, dest)
 		if err != nil {
 			return fmt.Errorf("simple engine: failed to extract %s from volume as %s: %w", output, dest, err)
 		}
 	}
 
-	slog.Debug("finished copying outputs", "engine", "simple", "job", job.Id, "volume", vol)
+	slog.Debug("finished copying outputs", "engine", "simple", "job", job.Id, "volume", outVol)
+
+	job.Release()
+
+	return nil
+}
+
+// stageInputs prepares the first volume for the job and copies the job
+// input into it under the name of each of the inputs of the first task.
+// If the job already has volumes, from an earlier attempt, the first
+// is cleared and reused, and the rest are released.
+func stageInputs(job *orchestrator.Job) error {
+	var vol task.Volume
+	var err error
+
+	if len(job.Volumes) > 0 {
+		vol, err = task.ClearOrReplace(job.Runner, job.Volumes[0])
+		if err == nil {
+			// The job keeps tracking every volume until the first
+			// has been reused, so none of them leak if it can't be.
+			job.Volumes = job.Volumes[1:]
+			job.Release()
+		}
+	} else {
+		vol, err = job.Runner.CreateVolume(0)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create volume %w", err)
+	}
+
+	job.Volumes = []task.Volume{vol}
+
+	slog.Debug("prepared job volume", "engine", "simple", "job", job.Id, "volume", vol)
+
+	firstTask := job.Tasks[0]
+	for _, input := range firstTask.Inputs {
+		err := job.Runner.AddFile(job.InPath, vol, input.File())
+		if err != nil {
+			return fmt.Errorf("simple engine: failed to copy %s to volume as %s: %w", job.InPath, input.Name, err)
+		} else {
+			slog.Debug("copied input to volume", "engine", "simple", "job", job.Id, "volume", vol, "input", job.InPath)
+		}
+	}
 
 	return nil
 }
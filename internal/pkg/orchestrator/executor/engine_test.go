@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/orchestrator"
+	"github.com/glesica/flowork/internal/pkg/task"
+)
+
+// unclearableRunner is a LocalRunner whose volumes can't be cleared.
+type unclearableRunner struct {
+	*task.LocalRunner
+}
+
+func (r *unclearableRunner) ClearVolume(v task.Volume) error {
+	return errors.New("volume is busy")
+}
+
+func Test_stageInputs(t *testing.T) {
+	t.Run("should keep the volumes of a job whose volume can't be cleared", func(t *testing.T) {
+		r := &unclearableRunner{&task.LocalRunner{
+			WorkDir: files.Dir(t.TempDir()),
+			Store:   &files.Local{},
+		}}
+
+		first, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+		second, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+
+		job := &orchestrator.Job{
+			Id:      "job",
+			Runner:  r,
+			Volumes: []task.Volume{first, second},
+		}
+
+		err = stageInputs(job)
+		assert.Error(t, err)
+		assert.Equal(t, []task.Volume{first, second}, job.Volumes)
+
+		job.Release()
+		for _, v := range []task.Volume{first, second} {
+			_, err := os.Stat(string(v))
+			assert.True(t, os.IsNotExist(err))
+		}
+	})
+}
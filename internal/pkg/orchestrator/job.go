@@ -1,6 +1,8 @@
 package orchestrator
 
 import (
+	"log/slog"
+
 	"github.com/glesica/flowork/internal/pkg/files"
 	"github.com/glesica/flowork/internal/pkg/task"
 )
@@ -9,11 +11,70 @@ import (
 // work. It also tracks its own state as it moves through the
 // execution machinery.
 type Job struct {
-	Id       string
-	Runner   task.Runner
-	Tasks    []*task.Instance
-	InPath   files.Path
-	OutDir   files.Dir
-	Err      error
+	Id     string
+	Runner task.Runner
+	Tasks  []*task.Instance
+	InPath files.Path
+	OutDir files.Dir
+	Err    error
+
+	// Attempts is the number of times the current (first unfinished)
+	// task has been attempted. It is reset whenever a task completes,
+	// so that a job that fails on its last task isn't penalized for
+	// the attempts it took to get there.
 	Attempts int
+
+	// Volumes holds the volumes the job has created, in task order.
+	// They are kept between attempts so that a retried job can resume
+	// at the task that failed. See Release.
+	Volumes []task.Volume
+
+	// KeepIntermediates disables pruning of intermediate files from
+	// the job volume between tasks.
+	KeepIntermediates bool
+
+	// IsolateTasks causes each task to run in its own volume, see
+	// task.RunIsolated.
+	IsolateTasks bool
+}
+
+// Completed returns the number of tasks, from the beginning of the
+// job, that have finished successfully. A retried job resumes at the
+// task with this index.
+func (j *Job) Completed() int {
+	for i, inst := range j.Tasks {
+		if !inst.Done() {
+			return i
+		}
+	}
+
+	return len(j.Tasks)
+}
+
+// Retryable indicates whether the job may be retried, given its
+// current state. If the first unfinished task sets its own limit
+// (see spec.Task.Retries), it is used instead of the given default.
+func (j *Job) Retryable(maxRetries int) bool {
+	if c := j.Completed(); c < len(j.Tasks) {
+		if r := j.Tasks[c].Retries; r != nil {
+			maxRetries = *r
+		}
+	}
+
+	return j.Attempts <= maxRetries
+}
+
+// Release deletes any volumes the job is holding on to. It should be
+// called once the job has either succeeded or been abandoned.
+func (j *Job) Release() {
+	for _, v := range j.Volumes {
+		err := j.Runner.DeleteVolume(v)
+		if err != nil {
+			slog.Error("failed to delete volume", "error", err, "job", j.Id, "volume", v)
+		} else {
+			slog.Debug("deleted job volume", "job", j.Id, "volume", v)
+		}
+	}
+
+	j.Volumes = nil
 }
@@ -0,0 +1,68 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/spec"
+	"github.com/glesica/flowork/internal/pkg/task"
+)
+
+func makeJob(t *testing.T, specs ...spec.Task) *Job {
+	job := &Job{}
+	for _, s := range specs {
+		inst, err := task.NewInstance(s)
+		assert.NoError(t, err)
+		job.Tasks = append(job.Tasks, inst)
+	}
+
+	return job
+}
+
+func finish(inst *task.Instance, err string) {
+	inst.Result.Started = time.Now()
+	inst.Result.Finished = time.Now()
+	inst.Result.Error = err
+}
+
+func TestJob_Completed(t *testing.T) {
+	job := makeJob(t, spec.Task{Name: "a"}, spec.Task{Name: "b"}, spec.Task{Name: "c"})
+	assert.Equal(t, 0, job.Completed())
+
+	finish(job.Tasks[0], "")
+	assert.Equal(t, 1, job.Completed())
+
+	finish(job.Tasks[1], "failed")
+	assert.Equal(t, 1, job.Completed())
+
+	finish(job.Tasks[1], "")
+	finish(job.Tasks[2], "")
+	assert.Equal(t, 3, job.Completed())
+}
+
+func TestJob_Retryable(t *testing.T) {
+	three := 3
+
+	t.Run("should use the default without tasks", func(t *testing.T) {
+		job := &Job{Attempts: 2}
+		assert.True(t, job.Retryable(2))
+		assert.False(t, job.Retryable(1))
+	})
+
+	t.Run("should use the limit of the unfinished task", func(t *testing.T) {
+		job := makeJob(t, spec.Task{Name: "a"}, spec.Task{Name: "b", Retries: &three})
+		job.Attempts = 3
+
+		// The first task uses the default.
+		assert.False(t, job.Retryable(1))
+
+		// The second task uses its own limit.
+		finish(job.Tasks[0], "")
+		assert.True(t, job.Retryable(1))
+
+		job.Attempts = 4
+		assert.False(t, job.Retryable(1))
+	})
+}
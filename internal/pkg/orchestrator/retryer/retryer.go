@@ -60,17 +60,19 @@ func (p *worker) run() {
 			break
 		}
 
-		if job.Attempts > p.maxRetries {
+		if !job.Retryable(p.maxRetries) {
 			// Job has already been tried the maximum number of times,
 			// so we abandon it and consider it a failure
 			failureCount++
 			slog.Info("job failed", "id", job.Id, "inpath", job.InPath)
+			job.Release()
 			continue
 		}
 
 		if failureCount >= p.maxFailures {
 			// We surpassed our max failures, so we prepare to shut
 			// down by no longer retrying jobs
+			job.Release()
 			continue
 		}
 
@@ -82,7 +84,8 @@ func (p *worker) run() {
 }
 
 // WithMaxRetries sets the maximum number of times a failed job
-// will be retried before it becomes a failure.
+// will be retried before it becomes a failure. Tasks may override
+// this for themselves, see orchestrator.Job.Retryable.
 func WithMaxRetries(value int) option.Func[*worker] {
 	return func(p *worker) error {
 		if value < 0 {
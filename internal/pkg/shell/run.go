@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os/exec"
 )
@@ -15,32 +16,52 @@ type Result struct {
 }
 
 func Run(cmd []string) (*Result, error) {
-	slog.Debug("running shell command", "command", cmd)
-	c := exec.Command(cmd[0], cmd[1:]...)
+	return RunDir("", cmd)
+}
 
+// RunDir is like Run, but runs the command in the given directory.
+// If the directory is empty, the current working directory is used.
+func RunDir(dir string, cmd []string) (*Result, error) {
 	outBuf, errBuf := bytes.Buffer{}, bytes.Buffer{}
-	c.Stdout = &outBuf
-	c.Stderr = &errBuf
 
-	err := c.Run()
-	var exitErr *exec.ExitError
-	if errors.As(err, &exitErr) {
-		r := &Result{
-			Code: exitErr.ExitCode(),
-			Out:  outBuf.String(),
-			Err:  errBuf.String(),
-		}
-		return r, fmt.Errorf("failed to run command (%s): %w", c.String(), exitErr)
-	} else if err != nil {
+	code, err := Stream(dir, cmd, &outBuf, &errBuf)
+	if code < 0 {
 		return nil, err
 	}
 
 	r := &Result{
-		Code: 0,
+		Code: code,
 		Out:  outBuf.String(),
 		Err:  errBuf.String(),
 	}
+	if err != nil {
+		return r, err
+	}
+
 	slog.Debug("shell command complete", "result", r)
 
 	return r, nil
 }
+
+// Stream runs the given command in the given directory, writing its
+// output to the given writers as it is produced, rather than
+// collecting it. It returns the exit code of the command, or -1 if
+// the command could not be run at all. A non-zero exit code is also
+// reported as an error.
+func Stream(dir string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	slog.Debug("running shell command", "command", cmd, "dir", dir)
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Dir = dir
+	c.Stdout = stdout
+	c.Stderr = stderr
+
+	err := c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), fmt.Errorf("failed to run command (%s): %w", c.String(), exitErr)
+	} else if err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
@@ -1,10 +1,23 @@
 package spec
 
 import (
+	"errors"
 	"fmt"
+	"path"
 	"regexp"
+	"strings"
+	"time"
+
+	"github.com/glesica/flowork/internal/pkg/files"
 )
 
+// Filter selects workflow inputs. A file matches the filter only if it
+// matches every criterion that is set.
+//
+// Examples:
+//   - {"ext": "fastq.gz"}
+//   - {"glob": "sample_*", "min_size": 1024}
+//   - {"path": "/run[0-9]+/", "modified_since": "24h"}
 type Filter struct {
 	// Path provides a regular expression, matched against the full,
 	// absolute file path. Only paths that match will be passed through.
@@ -13,37 +26,190 @@ type Filter struct {
 	//   - /home/bob/data/file.csv
 	//   - aws://bobs-bucket/data/file.csv
 	//   - https://bob.net/data/file.csv
-	Path string `json:"path"`
+	Path string `json:"path,omitempty" toml:"path,omitempty"`
+
+	// Ext is a file extension, with or without the leading dot, like
+	// "csv" or "fastq.gz".
+	Ext string `json:"ext,omitempty" toml:"ext,omitempty"`
+
+	// Glob is a shell pattern, like "sample_*.csv". It is matched
+	// against the file name or, if it contains a slash, the full path.
+	Glob string `json:"glob,omitempty" toml:"glob,omitempty"`
+
+	// MinSize is the smallest file size, in bytes, that matches.
+	MinSize files.Size `json:"min_size,omitempty" toml:"min_size,omitempty"`
+
+	// MaxSize is the largest file size, in bytes, that matches. Zero
+	// means there is no limit.
+	MaxSize files.Size `json:"max_size,omitempty" toml:"max_size,omitempty"`
+
+	// ModifiedSince restricts the filter to files modified at or after
+	// a time, given as an RFC 3339 timestamp, a date like "2024-01-31",
+	// or a duration before the workflow is run, like "36h".
+	ModifiedSince string `json:"modified_since,omitempty" toml:"modified_since,omitempty"`
 }
 
+// FilterError is returned when a filter can't be compiled.
 type FilterError struct {
 	Message string
 	Filter  *Filter
-	URI     string
 	Wrapped error
 }
 
 func (e *FilterError) Error() string {
-	return fmt.Sprintf("%s: filter: %+v uri: %s", e.Message, e.Filter, e.URI)
+	if e.Wrapped == nil {
+		return fmt.Sprintf("%s: filter: %+v", e.Message, *e.Filter)
+	}
+
+	return fmt.Sprintf("%s: %s: filter: %+v", e.Message, e.Wrapped, *e.Filter)
 }
 
 func (e *FilterError) Unwrap() error {
 	return e.Wrapped
 }
 
-func (f *Filter) Match(uri string) (bool, error) {
-	pathMatch, err := regexp.MatchString(f.Path, uri)
-	if err != nil {
-		return false, &FilterError{
-			Message: "path regex failed",
-			Filter:  f,
-			URI:     uri,
-			Wrapped: err,
+// Compile checks the filter and returns a function that reports
+// whether a file matches it. Files with an unknown size, or
+// modification time, never match filters that depend on them.
+func (f Filter) Compile() (func(files.Info) bool, error) {
+	fail := func(msg string, err error) (func(files.Info) bool, error) {
+		return nil, &FilterError{Message: msg, Filter: &f, Wrapped: err}
+	}
+
+	var checks []func(files.Info) bool
+
+	if f.Path != "" {
+		r, err := regexp.Compile(f.Path)
+		if err != nil {
+			return fail("invalid path regex", err)
 		}
+		checks = append(checks, func(i files.Info) bool {
+			return r.MatchString(string(i.Path))
+		})
 	}
-	if pathMatch {
-		return true, nil
+
+	if f.Ext != "" {
+		ext := f.Ext
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		checks = append(checks, func(i files.Info) bool {
+			return strings.HasSuffix(i.Path.File(), ext)
+		})
+	}
+
+	if f.Glob != "" {
+		_, err := path.Match(f.Glob, "")
+		if err != nil {
+			return fail("invalid glob", err)
+		}
+		full := strings.Contains(f.Glob, "/")
+		checks = append(checks, func(i files.Info) bool {
+			name := i.Path.File()
+			if full {
+				name = string(i.Path)
+			}
+			ok, _ := path.Match(f.Glob, name)
+			return ok
+		})
 	}
 
-	return false, nil
+	if f.MinSize < 0 || f.MaxSize < 0 {
+		return fail("sizes must not be negative", nil)
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return fail("min_size must not be larger than max_size", nil)
+	}
+	if f.MinSize > 0 || f.MaxSize > 0 {
+		checks = append(checks, func(i files.Info) bool {
+			if i.Size == files.SizeUnknown || i.Size < f.MinSize {
+				return false
+			}
+			return f.MaxSize == 0 || i.Size <= f.MaxSize
+		})
+	}
+
+	if f.ModifiedSince != "" {
+		since, err := parseSince(f.ModifiedSince, time.Now())
+		if err != nil {
+			return fail("invalid modified_since", err)
+		}
+		checks = append(checks, func(i files.Info) bool {
+			return !i.ModTime.IsZero() && !i.ModTime.Before(since)
+		})
+	}
+
+	return func(i files.Info) bool {
+		for _, check := range checks {
+			if !check(i) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// parseSince parses the value of Filter.ModifiedSince. Durations are
+// counted back from now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s is not a timestamp, date, or duration", s)
+}
+
+// CompileFilters compiles a set of filters into a single function that
+// reports whether a file should be used as a workflow input. A file is
+// used if it matches any of the include filters, or there aren't any,
+// and none of the exclude filters. Every invalid filter is reported.
+func CompileFilters(include, exclude []Filter) (func(files.Info) bool, error) {
+	var errs []error
+	compile := func(fs []Filter) []func(files.Info) bool {
+		var matchers []func(files.Info) bool
+		for _, f := range fs {
+			m, err := f.Compile()
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			matchers = append(matchers, m)
+		}
+		return matchers
+	}
+
+	in := compile(include)
+	ex := compile(exclude)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return func(i files.Info) bool {
+		for _, m := range ex {
+			if m(i) {
+				return false
+			}
+		}
+
+		if len(in) == 0 {
+			return true
+		}
+		for _, m := range in {
+			if m(i) {
+				return true
+			}
+		}
+		return false
+	}, nil
 }
@@ -0,0 +1,95 @@
+package spec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestFilter_Compile(t *testing.T) {
+	now := time.Now()
+	info := func(p string, size files.Size, age time.Duration) files.Info {
+		return files.Info{Path: files.Path(p), Size: size, ModTime: now.Add(-age)}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter Filter
+		info   files.Info
+		match  bool
+	}{
+		{"empty", Filter{}, info("/d/a.txt", 1, 0), true},
+		{"path", Filter{Path: `^/d/[a-z]+\.txt$`}, info("/d/a.txt", 1, 0), true},
+		{"path mismatch", Filter{Path: `^/e/`}, info("/d/a.txt", 1, 0), false},
+		{"ext", Filter{Ext: "fastq.gz"}, info("/d/a.fastq.gz", 1, 0), true},
+		{"ext dot", Filter{Ext: ".txt"}, info("/d/a.txt", 1, 0), true},
+		{"ext mismatch", Filter{Ext: "txt"}, info("/d/txt", 1, 0), false},
+		{"glob", Filter{Glob: "a*.txt"}, info("/d/abc.txt", 1, 0), true},
+		{"glob mismatch", Filter{Glob: "b*"}, info("/d/abc.txt", 1, 0), false},
+		{"glob path", Filter{Glob: "/d/*.txt"}, info("/d/abc.txt", 1, 0), true},
+		{"min size", Filter{MinSize: 10}, info("/d/a.txt", 10, 0), true},
+		{"too small", Filter{MinSize: 10}, info("/d/a.txt", 9, 0), false},
+		{"too large", Filter{MaxSize: 10}, info("/d/a.txt", 11, 0), false},
+		{"unknown size", Filter{MaxSize: 10}, info("/d/a.txt", files.SizeUnknown, 0), false},
+		{"recent", Filter{ModifiedSince: "1h"}, info("/d/a.txt", 1, time.Minute), true},
+		{"old", Filter{ModifiedSince: "1h"}, info("/d/a.txt", 1, 2*time.Hour), false},
+		{"date", Filter{ModifiedSince: "2000-01-31"}, info("/d/a.txt", 1, 0), true},
+		{"unknown time", Filter{ModifiedSince: "2000-01-31T00:00:00Z"}, files.Info{Path: "/d/a.txt"}, false},
+		{"all", Filter{Ext: "txt", Glob: "a*", MinSize: 1}, info("/d/b.txt", 1, 0), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.filter.Compile()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.match, m(tt.info))
+		})
+	}
+
+	t.Run("should return an error for an invalid filter", func(t *testing.T) {
+		for _, f := range []Filter{
+			{Path: `(`},
+			{Glob: `[`},
+			{MinSize: -1},
+			{MinSize: 10, MaxSize: 5},
+			{ModifiedSince: "yesterday"},
+		} {
+			_, err := f.Compile()
+			var fe *FilterError
+			assert.True(t, errors.As(err, &fe), "%+v", f)
+		}
+	})
+}
+
+func TestCompileFilters(t *testing.T) {
+	m, err := CompileFilters(
+		[]Filter{{Ext: "txt"}, {Ext: "csv"}},
+		[]Filter{{Glob: "skip*"}},
+	)
+	assert.NoError(t, err)
+
+	for p, match := range map[files.Path]bool{
+		"/d/a.txt":    true,
+		"/d/a.csv":    true,
+		"/d/a.json":   false,
+		"/d/skip.txt": false,
+	} {
+		assert.Equal(t, match, m(files.Info{Path: p}), "%s", p)
+	}
+
+	t.Run("should use everything with no include filters", func(t *testing.T) {
+		m, err := CompileFilters(nil, []Filter{{Ext: "txt"}})
+		assert.NoError(t, err)
+		assert.True(t, m(files.Info{Path: "/d/a.csv"}))
+		assert.False(t, m(files.Info{Path: "/d/a.txt"}))
+	})
+
+	t.Run("should report every invalid filter", func(t *testing.T) {
+		_, err := CompileFilters([]Filter{{Path: `(`}}, []Filter{{Glob: `[`}})
+		assert.Error(t, err)
+		assert.Equal(t, 2, len(strings.Split(err.Error(), "\n")))
+	})
+}
@@ -0,0 +1,768 @@
+package spec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a file format that workflows and tasks can be written in.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+)
+
+// Formats returns the supported formats.
+func Formats() []Format {
+	return []Format{FormatJSON, FormatTOML, FormatYAML}
+}
+
+// ParseFormat returns the format with the given name, which may also
+// be a file extension, like ".yml".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "json":
+		return FormatJSON, nil
+	case "toml":
+		return FormatTOML, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	}
+
+	return "", fmt.Errorf("unknown format (%s)", name)
+}
+
+// FormatOf determines the format of a document based on the extension
+// of its path, if it has a known one, or by sniffing its contents
+// otherwise.
+func FormatOf(path string, raw []byte) Format {
+	f, err := ParseFormat(filepath.Ext(path))
+	if err == nil {
+		return f
+	}
+
+	return sniff(raw)
+}
+
+// tomlLine matches the lines that are most likely to give away a TOML
+// document: table headers and "key = value" pairs.
+var tomlLine = regexp.MustCompile(`^(\[\[?[A-Za-z0-9_."' -]+\]\]?|[A-Za-z0-9_"'.-]+\s*=)`)
+
+// sniff guesses the format of a document from its contents. JSON is
+// recognized by its opening brace, TOML by its first significant line,
+// and anything else is assumed to be YAML.
+func sniff(raw []byte) Format {
+	trimmed := bytes.TrimSpace(raw)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatJSON
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if tomlLine.MatchString(line) {
+			return FormatTOML
+		}
+		break
+	}
+
+	return FormatYAML
+}
+
+// ParseError is an error in a workflow or task document that can be
+// traced to a particular location. Line and Column start at 1, and
+// either may be zero if it isn't known.
+type ParseError struct {
+	Format Format
+	Line   int
+	Column int
+
+	// Field is the path of the field that couldn't be decoded, like
+	// tasks[0].cmd, if known.
+	Field string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var loc strings.Builder
+	loc.WriteString(string(e.Format))
+	if e.Line > 0 {
+		fmt.Fprintf(&loc, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&loc, ":%d", e.Column)
+		}
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&loc, " (%s)", e.Field)
+	}
+
+	return fmt.Sprintf("%s: %s", loc.String(), e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// decode parses a document in the given format into v. Every format
+// is first converted to JSON, so that the JSON struct tags, and custom
+// unmarshalers, apply to all of them. Fields that the schema node
+// doesn't allow are rejected.
+func decode(raw []byte, f Format, v any, node map[string]any) error {
+	var doc []byte
+	var pos positions
+	var err error
+
+	switch f {
+	case FormatJSON:
+		doc, pos = raw, jsonPositions(raw)
+	case FormatTOML:
+		doc, pos, err = tomlToJSON(raw)
+	case FormatYAML:
+		doc, pos, err = yamlToJSON(raw)
+	default:
+		return fmt.Errorf("unknown format (%s)", f)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = checkFields(doc, f, node, pos)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(doc, v)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if f == FormatJSON && errors.As(err, &syntaxErr) {
+			// The offset is just past the byte that caused the error.
+			line, column := textPositions(raw).at(syntaxErr.Offset - 1)
+			return &ParseError{Format: f, Line: line, Column: column, Err: err}
+		}
+
+		return jsonError(f, err, doc, pos)
+	}
+
+	return nil
+}
+
+// jsonError converts an error from decoding the JSON document into a
+// ParseError, using the positions, if there are any, to find its
+// location in the original document.
+func jsonError(f Format, err error, doc []byte, pos positions) error {
+	pe := &ParseError{Format: f, Err: err}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		// The offset is just past the end of the value.
+		offset := typeErr.Offset - 1
+		pe.Line, pe.Column = pos.at(offset)
+		pe.Field = typeErr.Field
+		pe.Err = fmt.Errorf("cannot use %s as %s", typeErr.Value, typeErr.Type)
+
+		// The decoder leaves out array indexes, so the path is taken
+		// from the document instead, to match unknownFields.
+		if field, ok := jsonFields(doc).at(offset); ok && field.path != "" {
+			pe.Field = field.path
+		}
+	}
+
+	return pe
+}
+
+// positions maps offsets in a JSON document to lines and columns in
+// the document it was converted from. Offsets are sorted.
+type positions []position
+
+type position struct {
+	offset int64
+	line   int
+	column int
+}
+
+// at returns the line and column of the value that starts at, or most
+// recently before, the given offset.
+func (p positions) at(offset int64) (int, int) {
+	i := sort.Search(len(p), func(i int) bool {
+		return p[i].offset > offset
+	})
+	if i == 0 {
+		return 0, 0
+	}
+
+	return p[i-1].line, p[i-1].column
+}
+
+// jsonPositions records the position of every token in a JSON
+// document, so that the errors the JSON decoder reports at the end of
+// a value can be traced back to its start.
+func jsonPositions(raw []byte) positions {
+	text := textPositions(raw)
+
+	var pos positions
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		start := tokenStart(raw, dec.InputOffset())
+		_, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		line, column := text.at(start)
+		pos = append(pos, position{offset: start, line: line, column: column})
+	}
+
+	return pos
+}
+
+// tokenStart skips the whitespace and separators, which aren't tokens,
+// from the given offset in a JSON document, to find where the next
+// token starts.
+func tokenStart(raw []byte, offset int64) int64 {
+	for offset < int64(len(raw)) && strings.IndexByte(" \t\r\n,:", raw[offset]) >= 0 {
+		offset++
+	}
+
+	return offset
+}
+
+// jsonField is a value in a JSON document, located by offsets.
+type jsonField struct {
+	// path is the path of the value, like tasks[0].cmd (see
+	// unknownFields), which is empty for the document itself.
+	path string
+
+	// key is the offset of the key of the value, or -1 if it doesn't
+	// have one, because it is an array item or the document itself.
+	key int64
+
+	// start and end are the offsets of the first byte of the value,
+	// and just past the last.
+	start, end int64
+}
+
+type jsonFieldList []jsonField
+
+// at returns the innermost value that contains the given offset.
+func (l jsonFieldList) at(offset int64) (jsonField, bool) {
+	var found jsonField
+	var ok bool
+	for _, f := range l {
+		if f.start <= offset && offset < f.end && (!ok || f.start >= found.start) {
+			found, ok = f, true
+		}
+	}
+
+	return found, ok
+}
+
+// byPath returns the values in the list by path.
+func (l jsonFieldList) byPath() map[string]jsonField {
+	m := make(map[string]jsonField, len(l))
+	for _, f := range l {
+		m[f.path] = f
+	}
+
+	return m
+}
+
+// jsonFields locates every value in a JSON document, which is assumed
+// to be valid.
+func jsonFields(raw []byte) jsonFieldList {
+	type container struct {
+		field   int
+		object  bool
+		wantKey bool
+		key     string
+		keyAt   int64
+		index   int
+	}
+
+	var fields jsonFieldList
+	var stack []*container
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		start := tokenStart(raw, dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			fields[top.field].end = dec.InputOffset()
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if top != nil && top.wantKey {
+			top.key, _ = tok.(string)
+			top.keyAt = start
+			top.wantKey = false
+			continue
+		}
+
+		field := jsonField{key: -1, start: start, end: dec.InputOffset()}
+		if top != nil && top.object {
+			field.path = joinPath(fields[top.field].path, top.key)
+			field.key = top.keyAt
+			top.wantKey = true
+		} else if top != nil {
+			field.path = fmt.Sprintf("%s[%d]", fields[top.field].path, top.index)
+			top.index++
+		}
+		fields = append(fields, field)
+
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, &container{
+				field:   len(fields) - 1,
+				object:  d == '{',
+				wantKey: d == '{',
+			})
+		}
+	}
+
+	return fields
+}
+
+// textPositions maps every offset in a document to its line and
+// column.
+func textPositions(raw []byte) positions {
+	pos := positions{{offset: 0, line: 1, column: 1}}
+	line, column := 1, 1
+	for i, b := range raw {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		pos = append(pos, position{offset: int64(i + 1), line: line, column: column})
+	}
+
+	return pos
+}
+
+// yamlLine finds the line number in a YAML syntax error, since the
+// YAML package doesn't expose it any other way.
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlToJSON converts a YAML document to JSON, recording the position
+// of every key and value along the way.
+func yamlToJSON(raw []byte) ([]byte, positions, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(raw, &root)
+	if err != nil {
+		pe := &ParseError{Format: FormatYAML, Err: err}
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			pe.Line, _ = strconv.Atoi(m[1])
+			pe.Err = errors.New(m[2])
+		}
+		return nil, nil, pe
+	}
+
+	c := &yamlConverter{}
+	if len(root.Content) > 0 {
+		err = c.convert(root.Content[0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return c.buf.Bytes(), c.pos, nil
+}
+
+type yamlConverter struct {
+	buf bytes.Buffer
+	pos positions
+}
+
+func (c *yamlConverter) convert(n *yaml.Node) error {
+	c.pos = append(c.pos, position{offset: int64(c.buf.Len()), line: n.Line, column: n.Column})
+
+	fail := func(msg string) error {
+		return &ParseError{Format: FormatYAML, Line: n.Line, Column: n.Column, Err: errors.New(msg)}
+	}
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return c.convert(n.Alias)
+	case yaml.MappingNode:
+		c.buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return fail("mapping keys must be strings")
+			}
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			c.pos = append(c.pos, position{offset: int64(c.buf.Len()), line: key.Line, column: key.Column})
+			k, _ := json.Marshal(key.Value)
+			c.buf.Write(k)
+			c.buf.WriteByte(':')
+			err := c.convert(value)
+			if err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte('}')
+	case yaml.SequenceNode:
+		c.buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			err := c.convert(item)
+			if err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte(']')
+	case yaml.ScalarNode:
+		var value any
+		err := n.Decode(&value)
+		if err != nil {
+			return fail(err.Error())
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fail(fmt.Sprintf("unsupported value %q", n.Value))
+		}
+		c.buf.Write(data)
+	default:
+		return fail("unsupported YAML node")
+	}
+
+	return nil
+}
+
+// tomlToJSON converts a TOML document to JSON, recording the position
+// of every key and value along the way. The TOML package only reports
+// positions for syntax errors, so they are found by scanning the
+// document (see tomlPositions).
+func tomlToJSON(raw []byte) ([]byte, positions, error) {
+	var doc map[string]any
+	_, err := toml.Decode(string(raw), &doc)
+	if err != nil {
+		pe := &ParseError{Format: FormatTOML, Err: err}
+
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			pe.Line, pe.Column = textPositions(raw).at(int64(parseErr.Position.Start))
+			pe.Err = errors.New(parseErr.Message)
+		}
+
+		return nil, nil, pe
+	}
+
+	c := &tomlConverter{}
+	c.keys, c.values = tomlPositions(raw)
+	err = c.convert("", doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.buf.Bytes(), c.pos, nil
+}
+
+type tomlConverter struct {
+	buf bytes.Buffer
+	pos positions
+
+	// keys and values hold the positions of the keys, and their
+	// values, in the TOML document, by field path.
+	keys   map[string]position
+	values map[string]position
+}
+
+// mark records the position of the key or value at the given path, if
+// it is known, as the position of whatever is written next.
+func (c *tomlConverter) mark(at map[string]position, path string) {
+	p, ok := at[path]
+	if !ok {
+		// Inline tables and arrays, for example, fall back to the
+		// position of the value that contains them.
+		return
+	}
+
+	c.pos = append(c.pos, position{offset: int64(c.buf.Len()), line: p.line, column: p.column})
+}
+
+func (c *tomlConverter) convert(path string, v any) error {
+	c.mark(c.values, path)
+
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		c.buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			c.mark(c.keys, joinPath(path, k))
+			data, _ := json.Marshal(k)
+			c.buf.Write(data)
+			c.buf.WriteByte(':')
+			err := c.convert(joinPath(path, k), v[k])
+			if err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte('}')
+	case []map[string]any:
+		// Arrays of tables.
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return c.convertArray(path, items)
+	case []any:
+		return c.convertArray(path, v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			line, column := c.pos.at(int64(c.buf.Len()))
+			return &ParseError{Format: FormatTOML, Line: line, Column: column, Field: path, Err: err}
+		}
+		c.buf.Write(data)
+	}
+
+	return nil
+}
+
+func (c *tomlConverter) convertArray(path string, items []any) error {
+	c.buf.WriteByte('[')
+	for i, item := range items {
+		if i > 0 {
+			c.buf.WriteByte(',')
+		}
+		err := c.convert(fmt.Sprintf("%s[%d]", path, i), item)
+		if err != nil {
+			return err
+		}
+	}
+	c.buf.WriteByte(']')
+
+	return nil
+}
+
+// tomlKey matches the key, which may be dotted, and the equals sign at
+// the start of a key/value pair.
+var tomlKey = regexp.MustCompile(`^((?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*')(?:[ \t]*\.[ \t]*(?:[A-Za-z0-9_-]+|"(?:[^"\\]|\\.)*"|'[^']*'))*)[ \t]*=[ \t]*`)
+
+// tomlPositions scans a TOML document, which must be valid, for the
+// positions of its keys and values, by field path (see unknownFields).
+// Tables are positioned at their headers. Only pairs that start a line
+// are found, so the contents of inline tables and arrays aren't.
+func tomlPositions(raw []byte) (keys, values map[string]position) {
+	keys = map[string]position{}
+	values = map[string]position{}
+
+	// counts holds the number of tables seen so far in each array of
+	// tables, so that later headers refer to the latest one.
+	counts := map[string]int{}
+	resolve := func(segments []string) string {
+		var path string
+		for _, s := range segments {
+			path = joinPath(path, s)
+			if n, ok := counts[path]; ok {
+				path = fmt.Sprintf("%s[%d]", path, n-1)
+			}
+		}
+		return path
+	}
+
+	var table string
+	var multiline string
+	for i, line := range strings.Split(string(raw), "\n") {
+		if multiline != "" {
+			// Keys in multi-line strings aren't keys.
+			if strings.Contains(line, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+
+		trimmed := strings.TrimLeft(line, " \t")
+		at := position{line: i + 1, column: len(line) - len(trimmed) + 1}
+
+		switch {
+		case strings.HasPrefix(trimmed, "[["):
+			end := strings.Index(trimmed, "]]")
+			if end < 0 {
+				continue
+			}
+			segments := splitTomlKey(trimmed[2:end])
+			array := joinPath(resolve(segments[:len(segments)-1]), segments[len(segments)-1])
+			if _, ok := counts[array]; !ok {
+				keys[array], values[array] = at, at
+			}
+			table = fmt.Sprintf("%s[%d]", array, counts[array])
+			counts[array]++
+			values[table] = at
+		case strings.HasPrefix(trimmed, "["):
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				continue
+			}
+			table = resolve(splitTomlKey(trimmed[1:end]))
+			keys[table], values[table] = at, at
+		default:
+			m := tomlKey.FindStringSubmatch(trimmed)
+			if m == nil {
+				continue
+			}
+			path := table
+			for _, s := range splitTomlKey(m[1]) {
+				path = joinPath(path, s)
+				keys[path] = at
+				values[path] = position{line: at.line, column: at.column + len(m[0])}
+			}
+
+			value := trimmed[len(m[0]):]
+			for _, quote := range []string{`"""`, `'''`} {
+				if strings.HasPrefix(value, quote) && !strings.Contains(value[len(quote):], quote) {
+					multiline = quote
+				}
+			}
+		}
+	}
+
+	return keys, values
+}
+
+// splitTomlKey splits a dotted TOML key into its parts, removing any
+// quotes.
+func splitTomlKey(key string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote byte
+	for i := 0; i < len(key); i++ {
+		b := key[i]
+		switch {
+		case quote != 0 && b == '\\' && quote == '"' && i+1 < len(key):
+			part.WriteByte(b)
+			part.WriteByte(key[i+1])
+			i++
+		case quote != 0 && b == quote:
+			quote = 0
+			part.WriteByte(b)
+		case quote != 0:
+			part.WriteByte(b)
+		case b == '"' || b == '\'':
+			quote = b
+			part.WriteByte(b)
+		case b == '.':
+			parts = append(parts, unquoteTomlKey(part.String()))
+			part.Reset()
+		default:
+			part.WriteByte(b)
+		}
+	}
+
+	return append(parts, unquoteTomlKey(part.String()))
+}
+
+func unquoteTomlKey(key string) string {
+	key = strings.TrimSpace(key)
+	if len(key) >= 2 && key[0] == '\'' && key[len(key)-1] == '\'' {
+		return key[1 : len(key)-1]
+	}
+	if strings.HasPrefix(key, `"`) {
+		if unquoted, err := strconv.Unquote(key); err == nil {
+			return unquoted
+		}
+	}
+
+	return key
+}
+
+// Encode writes v, which should be a Workflow or a Task, to the writer
+// in the given format.
+func Encode(w io.Writer, v any, f Format) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case FormatTOML:
+		enc := toml.NewEncoder(w)
+		enc.Indent = ""
+		return enc.Encode(v)
+	case FormatYAML:
+		// Going through JSON keeps the field names, and field order,
+		// consistent with the other formats without a second set of
+		// struct tags.
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		var root yaml.Node
+		err = yaml.Unmarshal(data, &root)
+		if err != nil {
+			return err
+		}
+		plainStyle(&root)
+
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		err = enc.Encode(&root)
+		if err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	return fmt.Errorf("unknown format (%s)", f)
+}
+
+// plainStyle removes the flow and quoting styles that a node picks up
+// from being parsed as JSON, so that it is written as idiomatic YAML.
+// Strings that would be read back as something else stay quoted.
+func plainStyle(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+		var value any
+		plain := &yaml.Node{Kind: yaml.ScalarNode, Value: n.Value}
+		if plain.Decode(&value) != nil {
+			return
+		}
+		if _, ok := value.(string); !ok {
+			return
+		}
+	}
+
+	n.Style = 0
+	for _, c := range n.Content {
+		plainStyle(c)
+	}
+}
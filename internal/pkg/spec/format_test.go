@@ -0,0 +1,167 @@
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestLoadWorkflowPath_formats(t *testing.T) {
+	expected, err := LoadWorkflowPath("fixtures/workflow.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(expected.Tasks))
+
+	for _, name := range []string{"fixtures/workflow.toml", "fixtures/workflow.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			w, err := LoadWorkflowPath(name)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, w)
+		})
+	}
+}
+
+func TestLoadWorkflow_sniff(t *testing.T) {
+	expected, err := LoadWorkflowPath("fixtures/workflow.json")
+	assert.NoError(t, err)
+
+	for _, name := range []string{"fixtures/workflow.json", "fixtures/workflow.toml", "fixtures/workflow.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(name)
+			assert.NoError(t, err)
+
+			w, err := LoadWorkflow(bytes.NewReader(raw))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, w)
+		})
+	}
+}
+
+func TestLoadWorkflow_errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		format Format
+		line   int
+		column int
+		field  string
+	}{
+		{
+			name:   "json syntax",
+			doc:    "{\n  \"name\": \"a\",\n  \"tasks\": [,]\n}",
+			format: FormatJSON,
+			line:   3,
+			column: 13,
+		},
+		{
+			name:   "json type",
+			doc:    "{\n  \"tasks\": [\n    {\"name\": \"a\", \"cmd\": \"ls\"}\n  ]\n}",
+			format: FormatJSON,
+			line:   3,
+			column: 26,
+			field:  "tasks[0].cmd",
+		},
+		{
+			name:   "yaml syntax",
+			doc:    "name: a\ntasks:\n  - name: b\n    cmd: [ls]\n  - name: \"c\n",
+			format: FormatYAML,
+			line:   5,
+		},
+		{
+			name:   "yaml type",
+			doc:    "name: a\ntasks:\n  - name: b\n    cmd: ls\n",
+			format: FormatYAML,
+			line:   4,
+			column: 10,
+			field:  "tasks[0].cmd",
+		},
+		{
+			name:   "toml syntax",
+			doc:    "name = \"a\"\n\n[[tasks]]\nname = b\n",
+			format: FormatTOML,
+			line:   4,
+			column: 8,
+		},
+		{
+			name:   "toml type",
+			doc:    "name = \"a\"\n\n[[tasks]]\nname = \"b\"\ncmd = \"ls\"\n",
+			format: FormatTOML,
+			line:   5,
+			column: 7,
+			field:  "tasks[0].cmd",
+		},
+		{
+			name:   "toml type in a later table",
+			doc:    "name = \"a\"\n\n[[tasks]]\nname = \"b\"\ncmd = [\"ls\"]\n\n[[tasks]]\nname = \"c\"\ndesc = \"\"\"\ncmd = 1\n\"\"\"\n  cmd   =   \"ls\"\n",
+			format: FormatTOML,
+			line:   12,
+			column: 13,
+			field:  "tasks[1].cmd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadWorkflow(strings.NewReader(tt.doc))
+			assert.Error(t, err)
+
+			var pe *ParseError
+			assert.True(t, errors.As(err, &pe), "expected a ParseError, got %v", err)
+			assert.Equal(t, tt.format, pe.Format)
+			assert.Equal(t, tt.line, pe.Line)
+			assert.Equal(t, tt.column, pe.Column)
+			assert.Equal(t, tt.field, pe.Field)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	expected, err := LoadWorkflowPath("fixtures/workflow.json")
+	assert.NoError(t, err)
+
+	for _, f := range Formats() {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Encode(&buf, expected, f)
+			assert.NoError(t, err)
+
+			w, err := loadWorkflow(buf.Bytes(), f)
+			assert.NoError(t, err, buf.String())
+			assert.Equal(t, expected, w)
+			assert.Equal(t, f, sniff(buf.Bytes()))
+		})
+	}
+}
+
+func Test_tomlPositions(t *testing.T) {
+	doc := strings.Join([]string{
+		`name = "a"`,
+		`params.count = 1`,
+		`[[tasks]]`,
+		`"quoted.key" = 2`,
+		`[[tasks.inputs]]`,
+		`name = "x"`,
+		`[[tasks]]`,
+		`[[tasks.inputs]]`,
+		`[[tasks.inputs]]`,
+		`  name = "y"`,
+	}, "\n")
+
+	keys, values := tomlPositions([]byte(doc))
+	for path, expected := range map[string]position{
+		"name":                    {line: 1, column: 8},
+		"params":                  {line: 2, column: 16},
+		"params.count":            {line: 2, column: 16},
+		"tasks[0]":                {line: 3, column: 1},
+		"tasks[0].quoted.key":     {line: 4, column: 16},
+		"tasks[0].inputs[0].name": {line: 6, column: 8},
+		"tasks[1].inputs[1]":      {line: 9, column: 1},
+		"tasks[1].inputs[1].name": {line: 10, column: 10},
+	} {
+		assert.Equal(t, expected, values[path], path)
+	}
+	assert.Equal(t, position{line: 10, column: 3}, keys["tasks[1].inputs[1].name"])
+}
@@ -0,0 +1,101 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// Input describes a file that must exist, relative to the working
+// directory, in order for a task to run. In workflow files, an input
+// may be given as a bare file name, or as an object that also
+// describes where the file comes from.
+//
+// Examples:
+//   - "data.csv"
+//   - {"name": "data.csv", "from": {"task": "parse", "output": "parsed.csv"}}
+type Input struct {
+	// Name is the name of the file that the task expects to find in
+	// its working directory.
+	Name files.Path `json:"name" toml:"name"`
+
+	// From, if set, indicates that the input is an output of an
+	// earlier task in the workflow, which will be made available to
+	// this task under Name. If it is not set, the input must be
+	// provided by the workflow input (for the first task) or by an
+	// earlier task that happens to use the same file name.
+	From *Source `json:"from,omitempty" toml:"from,omitempty"`
+}
+
+// Source identifies an output of a particular task.
+type Source struct {
+	// Task is the name of the task that produces the file.
+	Task string `json:"task" toml:"task"`
+
+	// Output is the name of the file, which must be one of the
+	// outputs of the task.
+	Output files.Path `json:"output" toml:"output"`
+}
+
+// File returns the file name of the input.
+func (i Input) File() string {
+	return i.Name.File()
+}
+
+func (i *Input) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var name files.Path
+		err := json.Unmarshal(data, &name)
+		if err != nil {
+			return err
+		}
+
+		*i = Input{Name: name}
+		return nil
+	}
+
+	// Use a different type to avoid recursing back into this method.
+	type input Input
+	var in input
+	err := json.Unmarshal(data, &in)
+	if err != nil {
+		return fmt.Errorf("input must be a file name or an object: %w", err)
+	}
+
+	*i = Input(in)
+	return nil
+}
+
+// MarshalTOML writes a bare input as a string and a wired one as an
+// inline table, mirroring MarshalJSON.
+func (i Input) MarshalTOML() ([]byte, error) {
+	if i.From == nil {
+		return json.Marshal(i.Name)
+	}
+
+	name, err := json.Marshal(i.Name)
+	if err != nil {
+		return nil, err
+	}
+	task, err := json.Marshal(i.From.Task)
+	if err != nil {
+		return nil, err
+	}
+	output, err := json.Marshal(i.From.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("{ name = %s, from = { task = %s, output = %s } }", name, task, output)), nil
+}
+
+func (i Input) MarshalJSON() ([]byte, error) {
+	if i.From == nil {
+		return json.Marshal(i.Name)
+	}
+
+	type input Input
+	return json.Marshal(input(i))
+}
@@ -0,0 +1,241 @@
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// TaskRef is a reference to a task defined in another file, which may
+// be used in place of a task in a workflow, or in a task file. The
+// referenced task is loaded, then the overrides, if any, are applied
+// on top of it.
+//
+// Examples:
+//   - {"ref": "tasks/align.json"}
+//   - {"ref": "https://example.com/align.yaml", "overrides": {"name": "align2"}}
+type TaskRef struct {
+	// Ref is the location of the task file. Relative locations are
+	// resolved relative to the file that contains the reference.
+	// Anything a files.Store accepts may be used.
+	Ref string `json:"ref" toml:"ref"`
+
+	// Overrides holds task fields that replace those in the
+	// referenced task.
+	Overrides json.RawMessage `json:"overrides,omitempty" toml:"overrides,omitempty"`
+}
+
+// Loader loads workflows and tasks from a store, resolving any task
+// references (see TaskRef) they contain. Every document it fetches is
+// cached, so a Loader can be reused to avoid fetching the same remote
+// task more than once.
+type Loader struct {
+	store files.Store
+
+	lock  sync.Mutex
+	cache map[files.Path][]byte
+}
+
+func NewLoader(s files.Store) *Loader {
+	return &Loader{
+		store: s,
+		cache: map[files.Path][]byte{},
+	}
+}
+
+// Workflow loads the workflow at the given location, which may be a
+// relative local path, and resolves its task references.
+func (l *Loader) Workflow(p files.Path) (Workflow, error) {
+	loc, err := resolveRef("", string(p))
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	raw, err := l.fetch(loc)
+	if err != nil {
+		return Workflow{}, fmt.Errorf("failed to open workflow file (%s): %w", p, err)
+	}
+
+	return l.workflow(raw, FormatOf(string(loc), raw), loc)
+}
+
+// Task loads the task at the given location, which may be a relative
+// local path, and resolves it if it is a reference.
+func (l *Loader) Task(p files.Path) (Task, error) {
+	loc, err := resolveRef("", string(p))
+	if err != nil {
+		return Task{}, err
+	}
+
+	return l.resolve(&TaskRef{Ref: string(loc)}, "", nil)
+}
+
+// workflow decodes a workflow and resolves its task references
+// relative to base, which is the location of the workflow, if known.
+func (l *Loader) workflow(raw []byte, f Format, base files.Path) (Workflow, error) {
+	w := Workflow{}
+
+	err := decode(raw, f, &w, workflowSchema)
+	if err != nil {
+		return w, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+
+	for i, t := range w.Tasks {
+		if t.TaskRef == nil {
+			continue
+		}
+
+		err = t.checkRef()
+		if err != nil {
+			return w, fmt.Errorf("invalid task %d: %w", i, err)
+		}
+
+		w.Tasks[i], err = l.resolve(t.TaskRef, base, []files.Path{base})
+		if err != nil {
+			return w, fmt.Errorf("failed to resolve task %d: %w", i, err)
+		}
+	}
+
+	// Only problems that would make the workflow meaningless are
+	// checked here, see Validator for the rest.
+	err = wiring(w).Err()
+	if err != nil {
+		return w, fmt.Errorf("invalid workflow: %w", err)
+	}
+
+	return w, nil
+}
+
+// resolve loads the task that the reference points to, following any
+// further references, and applies the overrides. The stack holds the
+// locations that led to this reference, to catch cycles.
+func (l *Loader) resolve(ref *TaskRef, base files.Path, stack []files.Path) (Task, error) {
+	loc, err := resolveRef(base, ref.Ref)
+	if err != nil {
+		return Task{}, err
+	}
+
+	for _, seen := range stack {
+		if seen == loc {
+			var chain []string
+			for _, s := range append(stack, loc) {
+				chain = append(chain, string(s))
+			}
+			return Task{}, fmt.Errorf("task reference cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	raw, err := l.fetch(loc)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to open task file (%s): %w", loc, err)
+	}
+
+	t, err := loadTask(raw, FormatOf(string(loc), raw))
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to load task (%s): %w", loc, err)
+	}
+
+	if t.TaskRef != nil {
+		err = t.checkRef()
+		if err != nil {
+			return Task{}, fmt.Errorf("invalid task (%s): %w", loc, err)
+		}
+
+		t, err = l.resolve(t.TaskRef, loc, append(stack, loc))
+		if err != nil {
+			return Task{}, err
+		}
+	}
+
+	if len(ref.Overrides) > 0 {
+		err = json.Unmarshal(ref.Overrides, &t)
+		if err != nil {
+			return Task{}, fmt.Errorf("failed to apply overrides to task (%s): %w", loc, err)
+		}
+		if t.TaskRef != nil {
+			return Task{}, fmt.Errorf("overrides for task (%s) may not contain a reference", loc)
+		}
+	}
+
+	return t, nil
+}
+
+// checkRef verifies that a task reference doesn't also set any of
+// the task fields, which would be ambiguous.
+func (t Task) checkRef() error {
+	if !reflect.DeepEqual(t, Task{TaskRef: t.TaskRef}) {
+		return fmt.Errorf("a task reference may only have ref and overrides")
+	}
+
+	return nil
+}
+
+// fetch returns the contents of the given file, loading it from the
+// store only if it hasn't been loaded before.
+func (l *Loader) fetch(p files.Path) ([]byte, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if raw, ok := l.cache[p]; ok {
+		return raw, nil
+	}
+
+	r, err := l.store.Load(p)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l.cache[p] = raw
+
+	return raw, nil
+}
+
+// resolveRef returns the location of ref, which is relative to the
+// location of the file it was found in (base). Local paths are made
+// absolute, since that is what files.Local expects, and relative paths
+// with no base are relative to the working directory.
+func resolveRef(base files.Path, ref string) (files.Path, error) {
+	u, err := url.Parse(ref)
+	if err == nil && u.Scheme != "" {
+		return files.Path(ref), nil
+	}
+
+	if path.IsAbs(ref) {
+		return files.Path(ref), nil
+	}
+
+	b, err := url.Parse(string(base))
+	if err == nil && b.Scheme != "" {
+		rel, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid task reference (%s): %w", ref, err)
+		}
+		return files.Path(b.ResolveReference(rel).String()), nil
+	}
+
+	dir := "."
+	if base != "" {
+		dir = filepath.Dir(string(base))
+	}
+
+	abs, err := filepath.Abs(filepath.Join(dir, ref))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve task reference (%s): %w", ref, err)
+	}
+
+	return files.Path(abs), nil
+}
@@ -0,0 +1,84 @@
+package spec
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// countingStore serves files from memory under a fake URL scheme and
+// counts how many times each one is loaded.
+type countingStore struct {
+	files map[files.Path]string
+	loads map[files.Path]int
+}
+
+func (s *countingStore) Accepts(p files.Path) bool {
+	return strings.HasPrefix(string(p), "mem://")
+}
+
+func (s *countingStore) Load(p files.Path) (io.ReadCloser, error) {
+	s.loads[p]++
+	return io.NopCloser(bytes.NewBufferString(s.files[p])), nil
+}
+
+func (s *countingStore) Save(p files.Path, f io.Reader) error { return nil }
+func (s *countingStore) Close() error                         { return nil }
+
+func TestLoader_Workflow(t *testing.T) {
+	t.Run("should resolve references relative to the workflow", func(t *testing.T) {
+		w, err := LoadWorkflowPath("fixtures/refs/workflow.yaml")
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, len(w.Tasks))
+		assert.Equal(t, "parse", w.Tasks[0].Name)
+		assert.Equal(t, "train-fast", w.Tasks[1].Name)
+		assert.Equal(t, []string{"train", "--fast", "data.csv"}, w.Tasks[1].Cmd)
+		assert.Equal(t, []files.Path{"model.bin"}, w.Tasks[1].Outputs)
+		assert.Zero(t, w.Tasks[1].TaskRef)
+	})
+
+	t.Run("should detect reference cycles", func(t *testing.T) {
+		_, err := LoadWorkflowPath("fixtures/refs/cycle/workflow.json")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "task reference cycle")
+		assert.Contains(t, err.Error(), "a.json -> ")
+	})
+
+	t.Run("should reject references with task fields", func(t *testing.T) {
+		_, err := LoadWorkflow(strings.NewReader(`{"tasks": [{"ref": "a.json", "name": "a"}]}`))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "(tasks[0].name): unknown field")
+	})
+
+	t.Run("should load from a store and cache fetches", func(t *testing.T) {
+		s := &countingStore{
+			files: map[files.Path]string{
+				"mem://defs/workflow.json": `{"tasks": [
+					{"ref": "tasks/echo.json"},
+					{"ref": "tasks/echo.json", "overrides": {"name": "echo2"}}
+				]}`,
+				"mem://defs/tasks/echo.json": `{"name": "echo", "cmd": ["echo"]}`,
+			},
+			loads: map[files.Path]int{},
+		}
+
+		w, err := NewLoader(s).Workflow("mem://defs/workflow.json")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "echo", w.Tasks[0].Name)
+		assert.Equal(t, "echo2", w.Tasks[1].Name)
+		assert.Equal(t, 1, s.loads["mem://defs/tasks/echo.json"])
+	})
+}
+
+func TestLoadTaskPath_ref(t *testing.T) {
+	task, err := LoadTaskPath("fixtures/refs/tasks/train.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "train", task.Name)
+}
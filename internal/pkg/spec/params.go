@@ -0,0 +1,177 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ParamType is the type of the value of a workflow parameter.
+type ParamType string
+
+const (
+	ParamString ParamType = "string"
+	ParamInt    ParamType = "int"
+	ParamFloat  ParamType = "float"
+	ParamBool   ParamType = "bool"
+)
+
+// ParamTypes returns the supported parameter types.
+func ParamTypes() []ParamType {
+	return []ParamType{ParamString, ParamInt, ParamFloat, ParamBool}
+}
+
+// Param declares a workflow parameter, which can be used in task
+// templates as {{.Params.<name>}} and set when the workflow is run.
+//
+// Examples:
+//   - {"type": "float", "default": 0.05, "desc": "p-value cutoff"}
+type Param struct {
+	// Type is the type of the parameter value, one of "string",
+	// "int", "float", or "bool". The default is "string".
+	Type ParamType `json:"type,omitempty" toml:"type,omitempty"`
+
+	// Default is the value used when none is given. A parameter with
+	// no default must be given a value when the workflow is run.
+	Default any `json:"default,omitempty" toml:"default,omitempty"`
+
+	// Desc is a human-readable description of the parameter.
+	Desc string `json:"desc,omitempty" toml:"desc,omitempty"`
+}
+
+func (t ParamType) orDefault() ParamType {
+	if t == "" {
+		return ParamString
+	}
+
+	return t
+}
+
+// Parse converts a string, usually given on the command line, into a
+// value of the type.
+func (t ParamType) Parse(s string) (any, error) {
+	switch t.orDefault() {
+	case ParamString:
+		return s, nil
+	case ParamInt:
+		return strconv.ParseInt(s, 10, 64)
+	case ParamFloat:
+		return strconv.ParseFloat(s, 64)
+	case ParamBool:
+		return strconv.ParseBool(s)
+	}
+
+	return nil, fmt.Errorf("unknown param type (%s)", t)
+}
+
+// Convert checks that a value, as decoded from a workflow file, has
+// the type, and normalizes it. Numbers decode as float64, so whole
+// numbers are converted to int64 for ParamInt.
+func (t ParamType) Convert(v any) (any, error) {
+	switch t.orDefault() {
+	case ParamString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case ParamInt:
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) {
+				return int64(n), nil
+			}
+		}
+	case ParamFloat:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int64:
+			return float64(n), nil
+		}
+	case ParamBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown param type (%s)", t)
+	}
+
+	return nil, fmt.Errorf("%v is not a valid %s", v, t.orDefault())
+}
+
+// zero returns the zero value of the type.
+func (t ParamType) zero() any {
+	switch t.orDefault() {
+	case ParamInt:
+		return int64(0)
+	case ParamFloat:
+		return float64(0)
+	case ParamBool:
+		return false
+	}
+
+	return ""
+}
+
+// ParamValues works out the value of each of the workflow parameters
+// from the given values, which are parsed according to the parameter
+// types, and the defaults. It is an error to give a value for a
+// parameter that doesn't exist, or to leave out a parameter with no
+// default. Every problem is reported.
+func (w Workflow) ParamValues(given map[string]string) (map[string]any, error) {
+	values := map[string]any{}
+	var errs []error
+
+	var unknown []string
+	for name := range given {
+		if _, ok := w.Params[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("unknown param %s", name))
+	}
+
+	for _, name := range w.paramNames() {
+		p := w.Params[name]
+
+		if s, ok := given[name]; ok {
+			v, err := p.Type.Parse(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for param %s: %w", name, err))
+				continue
+			}
+			values[name] = v
+			continue
+		}
+
+		if p.Default == nil {
+			errs = append(errs, fmt.Errorf("param %s has no default, so a value must be given", name))
+			continue
+		}
+
+		v, err := p.Type.Convert(p.Default)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid default for param %s: %w", name, err))
+			continue
+		}
+		values[name] = v
+	}
+
+	return values, errors.Join(errs...)
+}
+
+// paramNames returns the names of the parameters in sorted order.
+func (w Workflow) paramNames() []string {
+	var names []string
+	for name := range w.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,129 @@
+package spec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestWorkflow_ParamValues(t *testing.T) {
+	w, err := LoadWorkflow(strings.NewReader(`{
+		"params": {
+			"name": {"default": "sample"},
+			"count": {"type": "int", "default": 3},
+			"cutoff": {"type": "float", "default": 0.5},
+			"strict": {"type": "bool"}
+		},
+		"tasks": []
+	}`))
+	assert.NoError(t, err)
+
+	t.Run("should use defaults and parse given values", func(t *testing.T) {
+		values, err := w.ParamValues(map[string]string{"strict": "true", "cutoff": "0.1"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"name":   "sample",
+			"count":  int64(3),
+			"cutoff": 0.1,
+			"strict": true,
+		}, values)
+	})
+
+	t.Run("should report every problem", func(t *testing.T) {
+		_, err := w.ParamValues(map[string]string{"count": "many", "other": "x"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown param other")
+		assert.Contains(t, err.Error(), "invalid value for param count")
+		assert.Contains(t, err.Error(), "param strict has no default")
+	})
+
+	t.Run("should reject defaults of the wrong type", func(t *testing.T) {
+		w := Workflow{Params: map[string]Param{"count": {Type: ParamInt, Default: 1.5}}}
+		_, err := w.ParamValues(nil)
+		assert.EqualError(t, err, "invalid default for param count: 1.5 is not a valid int")
+	})
+}
+
+func TestTask_Render(t *testing.T) {
+	task := Task{
+		Name:  "align",
+		Cmd:   []string{"align", "--min", "{{.Params.min}}", "{{.Input.Name}}", "{{.Job.ID}}.bam"},
+		Image: "aligner:{{.Params.version}}",
+		Inputs: []Input{
+			{Name: "{{.Input.Name}}"},
+			{Name: "ref.fa", From: &Source{Task: "index", Output: "{{.Run.Name}}.fa"}},
+		},
+		Outputs: []files.Path{"{{.Input.Stem}}.bam"},
+	}
+
+	data := TemplateData{
+		Params: map[string]any{"min": int64(30), "version": "1.2"},
+		Input:  NewTemplateInput("/data/s1.fastq.gz", nil),
+		Job:    TemplateJob{ID: "j1"},
+		Run:    TemplateRun{ID: "r1", Name: "nightly"},
+	}
+
+	t.Run("should fill in templates", func(t *testing.T) {
+		rendered, err := task.Render(data)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"align", "--min", "30", "s1.fastq.gz", "j1.bam"}, rendered.Cmd)
+		assert.Equal(t, "aligner:1.2", rendered.Image)
+		assert.Equal(t, files.Path("s1.fastq.gz"), rendered.Inputs[0].Name)
+		assert.Equal(t, files.Path("nightly.fa"), rendered.Inputs[1].From.Output)
+		assert.Equal(t, []files.Path{"s1.bam"}, rendered.Outputs)
+
+		// The original task is left alone.
+		assert.Equal(t, "{{.Input.Name}}", task.Cmd[3])
+	})
+
+	t.Run("should reject undefined params", func(t *testing.T) {
+		_, err := task.Render(TemplateData{Params: map[string]any{"min": 1}})
+
+		var te *TemplateError
+		assert.True(t, errors.As(err, &te))
+		assert.Equal(t, "image", te.Field)
+		assert.Contains(t, err.Error(), `map has no entry for key "version"`)
+	})
+}
+
+func TestValidator_templates(t *testing.T) {
+	w := Workflow{
+		Params: map[string]Param{"min": {Type: ParamInt}},
+		Tasks: TaskSet{
+			validTask("a", nil, nil),
+		},
+	}
+	w.Tasks[0].Cmd = []string{"run", "{{.Params.min}}", "{{.Params.max}}", "{{.Nope}}", "{{"}
+
+	v, err := NewValidator()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"error -1 params",
+		"error 0 cmd[2]",
+		"error 0 cmd[3]",
+		"error 0 cmd[4]",
+	}, fields(v.Workflow(w)))
+
+	v, err = NewValidator(WithParams(map[string]string{"min": "1"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(v.Workflow(w)))
+}
+
+func TestValidator_metaColumns(t *testing.T) {
+	w := Workflow{Tasks: TaskSet{validTask("a", nil, nil)}}
+	w.Tasks[0].Cmd = []string{"run", "{{.Input.Meta.sample}}"}
+
+	v, err := NewValidator()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"error 0 cmd[1]"}, fields(v.Workflow(w)))
+
+	v, err = NewValidator(WithMetaColumns([]string{"sample"}))
+	assert.NoError(t, err)
+	assert.Equal(t, Diagnostics(nil), v.Workflow(w))
+}
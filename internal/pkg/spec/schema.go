@@ -0,0 +1,463 @@
+package spec
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//go:generate go run ./schemagen -o schema.json
+
+// schemaJSON is generated from the spec types, and their doc comments,
+// by GenerateSchema. Run go generate after changing them.
+//
+//go:embed schema.json
+var schemaJSON []byte
+
+// schemaRoot is the parsed form of schemaJSON, used to check documents
+// for unknown fields as they are loaded.
+var schemaRoot = func() map[string]any {
+	var root map[string]any
+	err := json.Unmarshal(schemaJSON, &root)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded schema: %s", err))
+	}
+	return root
+}()
+
+// SchemaID identifies the workflow schema.
+const SchemaID = "https://github.com/glesica/flowork/workflow.schema.json"
+
+// Schema returns the JSON Schema describing workflow files. It also
+// describes task files, under $defs.
+func Schema() []byte {
+	return schemaJSON
+}
+
+// schemaTypes are the types that get their own definitions.
+var schemaTypes = []reflect.Type{
+	reflect.TypeOf(Workflow{}),
+	reflect.TypeOf(Task{}),
+	reflect.TypeOf(TaskRef{}),
+	reflect.TypeOf(Input{}),
+	reflect.TypeOf(Source{}),
+	reflect.TypeOf(Param{}),
+	reflect.TypeOf(Filter{}),
+}
+
+// schemaRequired lists the required properties of each definition.
+var schemaRequired = map[string][]string{
+	"Workflow": {"tasks"},
+	"TaskRef":  {"ref"},
+	"Input":    {"name"},
+	"Source":   {"task", "output"},
+}
+
+// GenerateSchema builds the JSON Schema for workflow files from the
+// spec types, taking descriptions from the doc comments in the source
+// files found in the given directory.
+func GenerateSchema(srcDir string) ([]byte, error) {
+	docs, err := parseDocs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := map[string]any{}
+	for _, t := range schemaTypes {
+		defs[t.Name()] = typeSchema(t, docs)
+	}
+
+	root := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         SchemaID,
+		"title":       "Flowork workflow",
+		"description": docs["Workflow"],
+		"$ref":        "#/$defs/Workflow",
+		"$defs":       defs,
+	}
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// typeSchema describes one of the schemaTypes.
+func typeSchema(t reflect.Type, docs map[string]string) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous || !f.IsExported() {
+			// The embedded TaskRef has its own definition, which
+			// tasks in a workflow may be given as instead.
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		prop := fieldSchema(t, f)
+		if desc := docs[fieldKey(t.Name(), f.Name)]; desc != "" {
+			prop["description"] = desc
+		}
+		props[name] = prop
+	}
+
+	obj := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if req, ok := schemaRequired[t.Name()]; ok {
+		obj["required"] = req
+	}
+
+	s := obj
+	if t == reflect.TypeOf(Input{}) {
+		// An input may also be given as a bare file name.
+		s = map[string]any{
+			"anyOf": []any{map[string]any{"type": "string"}, obj},
+		}
+	}
+
+	if desc := docs[t.Name()]; desc != "" {
+		s["description"] = desc
+	}
+
+	return s
+}
+
+// fieldSchema describes the type of a field.
+func fieldSchema(parent reflect.Type, f reflect.StructField) map[string]any {
+	if parent == reflect.TypeOf(TaskRef{}) && f.Name == "Overrides" {
+		return map[string]any{"$ref": "#/$defs/Task"}
+	}
+
+	if parent == reflect.TypeOf(Param{}) && f.Name == "Type" {
+		var types []string
+		for _, t := range ParamTypes() {
+			types = append(types, string(t))
+		}
+		return map[string]any{"type": "string", "enum": types}
+	}
+
+	if parent == reflect.TypeOf(Workflow{}) && f.Name == "Tasks" {
+		return map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"anyOf": []any{
+					map[string]any{"$ref": "#/$defs/Task"},
+					map[string]any{"$ref": "#/$defs/TaskRef"},
+				},
+			},
+		}
+	}
+
+	return valueSchema(f.Type)
+}
+
+func valueSchema(t reflect.Type) map[string]any {
+	for _, st := range schemaTypes {
+		if t == st {
+			return map[string]any{"$ref": "#/$defs/" + t.Name()}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return valueSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": valueSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": valueSchema(t.Elem())}
+	}
+
+	return map[string]any{}
+}
+
+func fieldKey(typeName, fieldName string) string {
+	return typeName + "." + fieldName
+}
+
+// parseDocs collects the doc comments of the types, and their fields,
+// declared in the Go files in the given directory.
+func parseDocs(dir string) (map[string]string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source (%s): %w", dir, err)
+	}
+
+	docs := map[string]string{}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+
+					doc := ts.Doc
+					if doc == nil {
+						doc = gen.Doc
+					}
+					docs[ts.Name.Name] = docText(doc)
+
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						for _, name := range field.Names {
+							docs[fieldKey(ts.Name.Name, name.Name)] = docText(field.Doc)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return docs, nil
+}
+
+// docText turns a doc comment into a description, leaving out any TODO
+// notes, which are meant for developers.
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "TODO") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// workflowSchema describes a workflow file.
+var workflowSchema = map[string]any{"$ref": "#/$defs/Workflow"}
+
+// taskEntrySchema describes a task file, which may hold a task or a
+// reference to another task file.
+var taskEntrySchema = map[string]any{
+	"anyOf": []any{
+		map[string]any{"$ref": "#/$defs/Task"},
+		map[string]any{"$ref": "#/$defs/TaskRef"},
+	},
+}
+
+// unknownFields returns the paths of the fields in the document (as
+// decoded by encoding/json) that the schema doesn't allow. Only the
+// parts of JSON Schema that GenerateSchema uses are supported.
+func unknownFields(doc any, node map[string]any, path string) []string {
+	if ref, ok := node["$ref"].(string); ok {
+		def, ok := schemaDef(ref)
+		if !ok {
+			return nil
+		}
+		return unknownFields(doc, def, path)
+	}
+
+	if anyOf, ok := node["anyOf"].([]any); ok {
+		// Use the alternative that fits best, which is the one that
+		// has the most of its required fields (so {"ref": ...} is a
+		// reference, not a task), then the fewest unknown fields.
+		var best []string
+		bestRequired, matched := 0, false
+		for _, alt := range anyOf {
+			alt := alt.(map[string]any)
+			if !typeMatches(doc, alt) {
+				continue
+			}
+			unknown := unknownFields(doc, alt, path)
+			required := requiredFields(doc, alt)
+			better := required > bestRequired ||
+				required == bestRequired && len(unknown) < len(best)
+			if !matched || better {
+				best, bestRequired, matched = unknown, required, true
+			}
+		}
+		return best
+	}
+
+	var unknown []string
+	switch v := doc.(type) {
+	case map[string]any:
+		props, _ := node["properties"].(map[string]any)
+		closed := node["additionalProperties"] == false
+		extra, _ := node["additionalProperties"].(map[string]any)
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			prop, ok := props[k].(map[string]any)
+			if !ok && extra != nil {
+				prop, ok = extra, true
+			}
+			if !ok {
+				if closed {
+					unknown = append(unknown, joinPath(path, k))
+				}
+				continue
+			}
+			unknown = append(unknown, unknownFields(v[k], prop, joinPath(path, k))...)
+		}
+	case []any:
+		items, ok := node["items"].(map[string]any)
+		if !ok {
+			break
+		}
+		for i, item := range v {
+			unknown = append(unknown, unknownFields(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return unknown
+}
+
+// typeMatches indicates whether the document could be an instance of
+// the schema node, based only on its type.
+func typeMatches(doc any, node map[string]any) bool {
+	if ref, ok := node["$ref"].(string); ok {
+		def, ok := schemaDef(ref)
+		return ok && typeMatches(doc, def)
+	}
+
+	if anyOf, ok := node["anyOf"].([]any); ok {
+		for _, alt := range anyOf {
+			if typeMatches(doc, alt.(map[string]any)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch node["type"] {
+	case "object":
+		_, ok := doc.(map[string]any)
+		return ok
+	case "array":
+		_, ok := doc.([]any)
+		return ok
+	case "string":
+		_, ok := doc.(string)
+		return ok
+	}
+
+	return true
+}
+
+// requiredFields returns the number of the properties required by the
+// schema node that the document has, or -1 if it is missing any.
+func requiredFields(doc any, node map[string]any) int {
+	if ref, ok := node["$ref"].(string); ok {
+		def, ok := schemaDef(ref)
+		if !ok {
+			return 0
+		}
+		return requiredFields(doc, def)
+	}
+
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return 0
+	}
+
+	required, _ := node["required"].([]any)
+	for _, r := range required {
+		if _, ok := obj[r.(string)]; !ok {
+			return -1
+		}
+	}
+
+	return len(required)
+}
+
+// schemaDef looks up a reference to one of the definitions in the
+// embedded schema.
+func schemaDef(ref string) (map[string]any, bool) {
+	defs, ok := schemaRoot["$defs"].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	def, ok := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+	return def, ok
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// checkFields returns an error naming every field in the JSON document
+// that the schema node doesn't allow, using the positions, if there are
+// any, to find its key in the original document.
+func checkFields(doc []byte, f Format, node map[string]any, pos positions) error {
+	var v any
+	err := json.Unmarshal(doc, &v)
+	if err != nil {
+		// Syntax errors are reported, with positions, when the document
+		// is decoded for real.
+		return nil
+	}
+
+	unknown := unknownFields(v, node, "")
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	fields := jsonFields(doc).byPath()
+
+	var errs []error
+	for _, path := range unknown {
+		pe := &ParseError{
+			Format: f,
+			Field:  path,
+			Err:    errors.New("unknown field"),
+		}
+		if field, ok := fields[path]; ok && field.key >= 0 {
+			pe.Line, pe.Column = pos.at(field.key)
+		}
+		errs = append(errs, pe)
+	}
+
+	return errors.Join(errs...)
+}
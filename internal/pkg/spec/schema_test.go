@@ -0,0 +1,86 @@
+package spec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSchema(t *testing.T) {
+	t.Run("should be up to date", func(t *testing.T) {
+		generated, err := GenerateSchema(".")
+		assert.NoError(t, err)
+		assert.Equal(t, string(generated), string(Schema()), "run go generate in internal/pkg/spec")
+	})
+
+	t.Run("should include doc comments", func(t *testing.T) {
+		var root struct {
+			Defs map[string]struct {
+				Properties map[string]struct {
+					Description string `json:"description"`
+				} `json:"properties"`
+			} `json:"$defs"`
+		}
+		assert.NoError(t, json.Unmarshal(Schema(), &root))
+
+		desc := root.Defs["Task"].Properties["inputs"].Description
+		assert.Contains(t, desc, "Inputs is a list of files")
+		assert.NotContains(t, desc, "TODO")
+	})
+}
+
+func TestLoadWorkflow_unknownFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		field string
+	}{
+		{
+			name:  "task field",
+			doc:   `{"tasks": [{"name": "a", "ouputs": ["a.txt"]}]}`,
+			field: "json:1:26 (tasks[0].ouputs)",
+		},
+		{
+			name:  "yaml task field",
+			doc:   "tasks:\n  - name: a\n    ouputs: [a.txt]\n",
+			field: "yaml:3:5 (tasks[0].ouputs)",
+		},
+		{
+			name:  "toml task field",
+			doc:   "name = \"a\"\n\n[[tasks]]\nname = \"b\"\nouputs = [\"a.txt\"]\n",
+			field: "toml:5:1 (tasks[0].ouputs)",
+		},
+		{
+			name:  "workflow field",
+			doc:   "name: a\nkeep_intermediate: true\ntasks: []\n",
+			field: "yaml:2:1 (keep_intermediate)",
+		},
+		{
+			name:  "input field",
+			doc:   `{"tasks": [{"name": "a", "inputs": [{"name": "a.txt", "form": {}}]}]}`,
+			field: "json:1:55 (tasks[0].inputs[0].form)",
+		},
+		{
+			name:  "override field",
+			doc:   `{"tasks": [{"ref": "a.json", "overrides": {"imgae": "x"}}]}`,
+			field: "json:1:44 (tasks[0].overrides.imgae)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadWorkflow(strings.NewReader(tt.doc))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.field+": unknown field")
+		})
+	}
+
+	t.Run("should report every unknown field", func(t *testing.T) {
+		_, err := LoadWorkflow(strings.NewReader(`{"nmae": "a", "tasks": [{"cmdd": []}]}`))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "(nmae)")
+		assert.Contains(t, err.Error(), "(tasks[0].cmdd)")
+	})
+}
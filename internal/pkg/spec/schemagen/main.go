@@ -0,0 +1,27 @@
+// Command schemagen writes the JSON Schema for workflow files, see
+// spec.GenerateSchema. It is run by go generate in the spec package.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+func main() {
+	out := flag.String("o", "schema.json", "file to write the schema to")
+	src := flag.String("src", ".", "directory containing the spec package source")
+	flag.Parse()
+
+	data, err := spec.GenerateSchema(*src)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(*out, data, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,14 +1,14 @@
 package spec
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/glesica/flowork/internal/app/options"
 	"github.com/glesica/flowork/internal/pkg/files"
 	"io"
-	"os"
 )
 
+// Task is a single command, run in a container, that reads its inputs
+// from, and writes its outputs to, its working directory.
 type Task struct {
 	// Name is a human-readable name for the task, to be used in UI and
 	// logs as a quick way to reference a specific task. For example:
@@ -18,13 +18,16 @@ type Task struct {
 	// Desc is a human-readable description of the task, intended to be
 	// included in UIs and documentation. This should generally be about
 	// one sentence.
-	Desc string `json:"desc" toml:"desc"`
+	Desc string `json:"desc" toml:"desc,omitempty"`
 
 	// The command to run as an array of strings equivalent
-	// to an argv array, including the executable path.
+	// to an argv array, including the executable path. Like the
+	// image, inputs, and outputs, each argument may be a template
+	// (see TemplateData).
 	//
 	// Examples:
 	//   - []string{"ls", "-l", "/usr/bin"}
+	//   - []string{"filter", "--min", "{{.Params.min}}", "{{.Input.Name}}"}
 	Cmd []string `json:"cmd" toml:"cmd"`
 
 	// The Docker image that the command will run in. The
@@ -33,26 +36,34 @@ type Task struct {
 	//
 	// Examples:
 	//   - "debian:bookworm-slim"
-	Image string `json:"image" toml:"image"`
+	Image string `json:"image" toml:"image,omitempty"`
 
 	// The location within the container to mount the working directory
 	// and from which the command will be run.
 	//
 	// Examples:
 	//   - "/work"
-	WorkDir string `json:"workdir" toml:"workdir"`
+	WorkDir string `json:"workdir" toml:"workdir,omitempty"`
 
 	// Inputs is a list of files that must exist, relative to the
-	// working directory, in order for the task to run.
+	// working directory, in order for the task to run. Each input
+	// may name an output of an earlier task that it should be
+	// wired to (see Input).
 	// For now, these must be bare file names as they will only be
 	// copied directly into the working directory. In the future,
 	// full paths relative to the working directory will be supported.
 	// TODO: Support full paths for inputs
-	Inputs []files.Path `json:"inputs" toml:"inputs"`
+	Inputs []Input `json:"inputs" toml:"inputs,omitempty"`
 
 	// Outputs is a list of files that are guaranteed to exist, relative
 	// to the working directory, after the task has completed.
-	Outputs []files.Path `json:"outputs" toml:"outputs"`
+	Outputs []files.Path `json:"outputs" toml:"outputs,omitempty"`
+
+	// Retries, if set, is the number of times this task will be
+	// retried, after failing, before its job is abandoned. It
+	// overrides the default set for the whole workflow run. A job
+	// that is retried resumes at the task that failed.
+	Retries *int `json:"retries,omitempty" toml:"retries,omitempty"`
 
 	// DiskSpaceGB indicates the required amount of disk space
 	// available on the volume where the working directory is
@@ -64,29 +75,47 @@ type Task struct {
 	// task requires. The actual amount may be larger, but it
 	// will not be smaller.
 	// TODO: MemoryGB uint `json:"memory-gb"`
+
+	// TaskRef is set when the task is given as a reference to a task
+	// in another file, in which case no other fields may be set.
+	// References are resolved when workflows and tasks are loaded,
+	// so a loaded task never has one.
+	*TaskRef
 }
 
+// LoadTaskPath loads a task from a local file, in any of the supported
+// formats. The format is determined by the file extension or, failing
+// that, by the contents of the file. If the file contains a reference
+// to another task (see TaskRef), it is resolved. Use a Loader to load
+// tasks from other stores.
 func LoadTaskPath(p string) (Task, error) {
-	f, err := os.Open(p)
-	if err != nil {
-		return Task{}, fmt.Errorf("failed to open file (%s): %w", p, err)
-	}
-	defer func() { _ = f.Close() }()
-
-	return LoadTask(f)
+	return NewLoader(&files.Local{}).Task(files.Path(p))
 }
 
+// LoadTask loads a task in any of the supported formats, which is
+// determined by the contents. A reference to another task is resolved
+// relative to the working directory.
 func LoadTask(data io.Reader) (Task, error) {
-	c := Task{}
-
 	raw, err := io.ReadAll(data)
 	if err != nil {
-		return c, fmt.Errorf("failed to load task from JSON: %w", err)
+		return Task{}, fmt.Errorf("failed to load task: %w", err)
 	}
 
-	err = json.Unmarshal(raw, &c)
+	t, err := loadTask(raw, sniff(raw))
+	if err != nil || t.TaskRef == nil {
+		return t, err
+	}
+
+	return NewLoader(&files.Local{}).resolve(t.TaskRef, "", nil)
+}
+
+// loadTask decodes a task, which may be an unresolved reference.
+func loadTask(raw []byte, f Format) (Task, error) {
+	c := Task{}
+
+	err := decode(raw, f, &c, taskEntrySchema)
 	if err != nil {
-		return c, fmt.Errorf("failed to parse task from JSON: %w", err)
+		return c, fmt.Errorf("failed to parse task: %w", err)
 	}
 
 	return c, nil
@@ -0,0 +1,164 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// TemplateData is the data available to task templates. The Cmd,
+// Image, Inputs, and Outputs of a task may use Go template syntax to
+// refer to it, for example: {{.Params.threshold}}, {{.Input.Name}},
+// {{.Job.ID}}, or {{.Run.Name}}.
+type TemplateData struct {
+	// Params holds the values of the workflow parameters.
+	Params map[string]any
+
+	Input TemplateInput
+	Job   TemplateJob
+	Run   TemplateRun
+}
+
+// TemplateInput describes the workflow input a job was created for.
+type TemplateInput struct {
+	// Path is the full path to the input.
+	Path string
+
+	// Name is the file name of the input, like "sample1.fastq.gz".
+	Name string
+
+	// Stem is the file name with every extension removed, like
+	// "sample1".
+	Stem string
+
+	// Meta holds any metadata that came with the input, such as the
+	// other columns of its row in a manifest.
+	Meta map[string]string
+}
+
+// TemplateJob describes the job that a task is part of.
+type TemplateJob struct {
+	ID string
+}
+
+// TemplateRun describes the workflow run that a job is part of.
+type TemplateRun struct {
+	ID   string
+	Name string
+}
+
+// NewTemplateInput describes the input at the given path.
+func NewTemplateInput(p files.Path, meta map[string]string) TemplateInput {
+	name := p.File()
+	stem, _, _ := strings.Cut(name, ".")
+	if stem == "" {
+		// Hidden files, like ".env", are all extension otherwise.
+		stem = name
+	}
+
+	return TemplateInput{
+		Path: string(p),
+		Name: name,
+		Stem: stem,
+		Meta: meta,
+	}
+}
+
+// TemplateError is a problem with a template in a particular field of
+// a task.
+type TemplateError struct {
+	// Field identifies the field, like "cmd[1]" or "outputs[0]".
+	Field string
+	Err   error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Render returns a copy of the task with the templates in its Cmd,
+// Image, Inputs, and Outputs filled in from the data. It returns a
+// TemplateError for every template that couldn't be rendered. Using a
+// parameter that doesn't exist is an error.
+func (t Task) Render(data TemplateData) (Task, error) {
+	r := &renderer{data: data}
+
+	out := t
+	out.Image = r.render("image", t.Image)
+
+	out.Cmd = nil
+	for i, arg := range t.Cmd {
+		out.Cmd = append(out.Cmd, r.render(fmt.Sprintf("cmd[%d]", i), arg))
+	}
+
+	out.Inputs = nil
+	for i, in := range t.Inputs {
+		field := fmt.Sprintf("inputs[%d]", i)
+		rendered := Input{Name: files.Path(r.render(field, string(in.Name)))}
+		if in.From != nil {
+			rendered.From = &Source{
+				Task:   in.From.Task,
+				Output: files.Path(r.render(field+".from", string(in.From.Output))),
+			}
+		}
+		out.Inputs = append(out.Inputs, rendered)
+	}
+
+	out.Outputs = nil
+	for i, o := range t.Outputs {
+		out.Outputs = append(out.Outputs, files.Path(r.render(fmt.Sprintf("outputs[%d]", i), string(o))))
+	}
+
+	return out, errors.Join(r.errs...)
+}
+
+type renderer struct {
+	data TemplateData
+	errs []error
+}
+
+func (r *renderer) render(field, text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	tmpl, err := template.New(field).Option("missingkey=error").Parse(text)
+	if err != nil {
+		r.errs = append(r.errs, &TemplateError{Field: field, Err: err})
+		return text
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, r.data)
+	if err != nil {
+		r.errs = append(r.errs, &TemplateError{Field: field, Err: err})
+		return text
+	}
+
+	return b.String()
+}
+
+// sampleTemplateData returns data that can be used to check that
+// templates render, without a real input, job, or run. The input has
+// metadata with the given names.
+func sampleTemplateData(params map[string]any, metaColumns []string) TemplateData {
+	meta := map[string]string{}
+	for _, name := range metaColumns {
+		meta[name] = name
+	}
+
+	return TemplateData{
+		Params: params,
+		Input:  NewTemplateInput(files.Path(path.Join("/inputs", "sample.txt")), meta),
+		Job:    TemplateJob{ID: "job"},
+		Run:    TemplateRun{ID: "run", Name: "run"},
+	}
+}
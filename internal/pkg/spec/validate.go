@@ -1,7 +1,508 @@
 package spec
 
-import "github.com/glesica/flowork/internal/pkg/files"
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
 
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/option"
+)
+
+// Severity indicates whether a Diagnostic makes a workflow invalid.
+type Severity string
+
+const (
+	// SeverityError marks a problem that will prevent the workflow
+	// from running correctly.
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks something that is probably a mistake, but
+	// won't necessarily prevent the workflow from running.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a single problem found in a workflow or task.
+type Diagnostic struct {
+	Severity Severity
+
+	// Task is the index of the task the problem was found in, or -1
+	// if it applies to the workflow as a whole.
+	Task int
+
+	// TaskName is the name of the task, if it has one.
+	TaskName string
+
+	// Field identifies the field with the problem, like "cmd" or
+	// "inputs[1]", if there is one.
+	Field string
+
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: ", d.Severity)
+	if d.Task >= 0 {
+		fmt.Fprintf(&b, "task %d", d.Task)
+		if d.TaskName != "" {
+			fmt.Fprintf(&b, " (%s)", d.TaskName)
+		}
+		b.WriteString(": ")
+	}
+	if d.Field != "" {
+		fmt.Fprintf(&b, "%s: ", d.Field)
+	}
+	b.WriteString(d.Message)
+
+	return b.String()
+}
+
+// Diagnostics is a list of problems, in the order they were found.
+type Diagnostics []Diagnostic
+
+// Errors returns only the diagnostics with SeverityError.
+func (d Diagnostics) Errors() Diagnostics {
+	var errs Diagnostics
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			errs = append(errs, diag)
+		}
+	}
+
+	return errs
+}
+
+// Err returns a ValidationError holding the errors in the list, or
+// nil if there aren't any. Warnings are ignored.
+func (d Diagnostics) Err() error {
+	errs := d.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Diagnostics: errs}
+}
+
+// ValidationError is returned when a workflow or task has one or more
+// errors (see Diagnostics.Err).
+type ValidationError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, d := range e.Diagnostics {
+		msgs = append(msgs, d.String())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Validator checks workflows and tasks for problems that would keep
+// them from running, or that are probably mistakes. Every problem is
+// reported, not just the first.
+type Validator struct {
+	requireImages bool
+	params        map[string]string
+	metaColumns   []string
+}
+
+func NewValidator(opts ...option.Func[*Validator]) (*Validator, error) {
+	v := &Validator{
+		requireImages: true,
+	}
+
+	err := option.Apply(v, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("NewValidator: failed to apply options: %w", err)
+	}
+
+	return v, nil
+}
+
+// WithoutImages allows tasks with no image, for use with runners that
+// don't run tasks in containers (see task.LocalRunner).
+func WithoutImages() option.Func[*Validator] {
+	return func(v *Validator) error {
+		v.requireImages = false
+		return nil
+	}
+}
+
+// WithParams sets the parameter values the workflow will be run with,
+// as given on the command line (see Workflow.ParamValues).
+func WithParams(given map[string]string) option.Func[*Validator] {
+	return func(v *Validator) error {
+		v.params = given
+		return nil
+	}
+}
+
+// WithMetaColumns sets the names of the metadata that will come with
+// each input (see TemplateInput.Meta), such as the columns of a
+// manifest, so templates that use them can be checked.
+func WithMetaColumns(columns []string) option.Func[*Validator] {
+	return func(v *Validator) error {
+		v.metaColumns = columns
+		return nil
+	}
+}
+
+// Workflow checks the workflow, including each of its tasks.
+func (v *Validator) Workflow(w Workflow) Diagnostics {
+	var diags Diagnostics
+
+	if len(w.Tasks) == 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Task:     -1,
+			Field:    "tasks",
+			Message:  "workflow has no tasks",
+		})
+	}
+
+	names := map[string]int{}
+	for i, t := range w.Tasks {
+		for _, d := range v.Task(t) {
+			d.Task = i
+			diags = append(diags, d)
+		}
+
+		if t.Name == "" {
+			continue
+		}
+		if first, ok := names[t.Name]; ok {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Task:     i,
+				TaskName: t.Name,
+				Field:    "name",
+				Message:  fmt.Sprintf("name is already used by task %d", first),
+			})
+			continue
+		}
+		names[t.Name] = i
+	}
+
+	diags = append(diags, filters(w)...)
+	diags = append(diags, wiring(w)...)
+	diags = append(diags, availability(w)...)
+	diags = append(diags, v.templates(w)...)
+
+	return diags
+}
+
+// templates checks the parameter values and that every task template
+// can be rendered with them. Sample values are used for the input, job,
+// and run, since they aren't known until the workflow runs.
+func (v *Validator) templates(w Workflow) Diagnostics {
+	var diags Diagnostics
+
+	values, err := w.ParamValues(v.params)
+	for _, e := range splitErrors(err) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Task:     -1,
+			Field:    "params",
+			Message:  e.Error(),
+		})
+	}
+
+	// Parameters without values have been reported already, so fill
+	// them in to avoid reporting them again for every template.
+	for name, p := range w.Params {
+		if _, ok := values[name]; !ok {
+			values[name] = p.Type.zero()
+		}
+	}
+
+	data := sampleTemplateData(values, v.metaColumns)
+	for i, t := range w.Tasks {
+		_, err := t.Render(data)
+		for _, e := range splitErrors(err) {
+			d := Diagnostic{
+				Severity: SeverityError,
+				Task:     i,
+				TaskName: t.Name,
+				Message:  e.Error(),
+			}
+
+			var te *TemplateError
+			if errors.As(e, &te) {
+				d.Field = te.Field
+				d.Message = fmt.Sprintf("invalid template: %s", te.Err)
+			}
+
+			diags = append(diags, d)
+		}
+	}
+
+	return diags
+}
+
+// splitErrors returns the errors that make up an error created by
+// errors.Join, or the error itself, if it wasn't.
+func splitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+
+	return []error{err}
+}
+
+// Task checks a single task, without regard to any workflow it might
+// be a part of. The Task field of each diagnostic is zero.
+func (v *Validator) Task(t Task) Diagnostics {
+	var diags Diagnostics
+	report := func(s Severity, field, format string, args ...any) {
+		diags = append(diags, Diagnostic{
+			Severity: s,
+			TaskName: t.Name,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if t.Name == "" {
+		report(SeverityError, "name", "name is required")
+	}
+
+	if len(t.Cmd) == 0 {
+		report(SeverityError, "cmd", "cmd is required")
+	}
+
+	if v.requireImages && t.Image == "" {
+		report(SeverityError, "image", "image is required")
+	}
+
+	if t.WorkDir != "" && !path.IsAbs(t.WorkDir) {
+		report(SeverityError, "workdir", "workdir must be an absolute path, got %s", t.WorkDir)
+	}
+
+	if t.Retries != nil && *t.Retries < 0 {
+		report(SeverityError, "retries", "retries must not be negative")
+	}
+
+	inputs := map[string]int{}
+	for i, in := range t.Inputs {
+		field := fmt.Sprintf("inputs[%d]", i)
+
+		if in.Name == "" {
+			report(SeverityError, field, "input name is required")
+			continue
+		}
+		if in.File() != string(in.Name) {
+			report(SeverityError, field, "input %s must be a bare file name", in.Name)
+			continue
+		}
+		if first, ok := inputs[in.File()]; ok {
+			report(SeverityError, field, "input %s is already declared by inputs[%d]", in.Name, first)
+			continue
+		}
+		inputs[in.File()] = i
+	}
+
+	outputs := map[string]int{}
+	for i, out := range t.Outputs {
+		field := fmt.Sprintf("outputs[%d]", i)
+
+		if out == "" {
+			report(SeverityError, field, "output name is required")
+			continue
+		}
+		if first, ok := outputs[out.File()]; ok {
+			report(SeverityError, field, "output %s is already declared by outputs[%d]", out, first)
+			continue
+		}
+		outputs[out.File()] = i
+
+		if in, ok := inputs[out.File()]; ok {
+			report(SeverityWarning, field, "output %s has the same name as inputs[%d] and will overwrite it", out, in)
+		}
+	}
+
+	return diags
+}
+
+// filters checks that each of the input filters can be compiled.
+func filters(w Workflow) Diagnostics {
+	var diags Diagnostics
+
+	check := func(name string, fs []Filter) {
+		for i, f := range fs {
+			_, err := f.Compile()
+			if err == nil {
+				continue
+			}
+
+			msg := err.Error()
+			var fe *FilterError
+			if errors.As(err, &fe) {
+				msg = fe.Message
+				if fe.Wrapped != nil {
+					msg = fmt.Sprintf("%s: %s", fe.Message, fe.Wrapped)
+				}
+			}
+
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Task:     -1,
+				Field:    fmt.Sprintf("%s[%d]", name, i),
+				Message:  msg,
+			})
+		}
+	}
+
+	check("include", w.Include)
+	check("exclude", w.Exclude)
+
+	return diags
+}
+
+// wiring checks that every input that is wired to the output of
+// another task (see Input.From) refers to a declared output of an
+// earlier task in the workflow.
+func wiring(w Workflow) Diagnostics {
+	var diags Diagnostics
+
+	for i, t := range w.Tasks {
+		for j, in := range t.Inputs {
+			if in.From == nil {
+				continue
+			}
+
+			err := w.checkSource(i, *in.From)
+			if err != nil {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Task:     i,
+					TaskName: t.Name,
+					Field:    fmt.Sprintf("inputs[%d]", j),
+					Message:  fmt.Sprintf("input %s: %s", in.Name, err),
+				})
+			}
+		}
+	}
+
+	return diags
+}
+
+// checkSource verifies that the given source refers to an output of
+// one of the tasks before the task at the given index.
+func (w Workflow) checkSource(index int, from Source) error {
+	for _, earlier := range w.Tasks[:index] {
+		if earlier.Name != from.Task {
+			continue
+		}
+
+		for _, out := range earlier.Outputs {
+			if out == from.Output {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("task %s has no output %s", from.Task, from.Output)
+	}
+
+	return fmt.Errorf("no earlier task named %s", from.Task)
+}
+
+// availability checks that the inputs of each task, after the first,
+// that aren't wired to a particular task can be satisfied. Usually
+// they will be outputs of the previous task, but files that an earlier
+// task used, or produced, are also carried forward. The first task
+// gets its inputs from the workflow input, so it is skipped.
+func availability(w Workflow) Diagnostics {
+	var diags Diagnostics
+
+	for i := 1; i < len(w.Tasks); i++ {
+		t := w.Tasks[i]
+
+		var available []files.Path
+		for _, earlier := range w.Tasks[:i] {
+			available = append(available, earlier.Outputs...)
+			for _, in := range earlier.Inputs {
+				available = append(available, in.Name)
+			}
+		}
+
+		err := ValidateInputs(t, available)
+		if err != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Task:     i,
+				TaskName: t.Name,
+				Field:    "inputs",
+				Message:  err.Error(),
+			})
+			continue
+		}
+
+		// Relying on a file from further back is allowed, but it is
+		// worth pointing out in case it wasn't intentional.
+		prev := w.Tasks[i-1]
+		for j, in := range t.Inputs {
+			if in.From != nil || mentioned(prev, in.File()) {
+				continue
+			}
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Task:     i,
+				TaskName: t.Name,
+				Field:    fmt.Sprintf("inputs[%d]", j),
+				Message:  fmt.Sprintf("input %s isn't an output of the previous task (%s), it will come from an earlier task", in.Name, prev.Name),
+			})
+		}
+	}
+
+	return diags
+}
+
+// mentioned indicates whether the file is an input or an output of
+// the task.
+func mentioned(t Task, file string) bool {
+	for _, out := range t.Outputs {
+		if out.File() == file {
+			return true
+		}
+	}
+
+	for _, in := range t.Inputs {
+		if in.File() == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateInputs checks that each of the task's inputs that isn't
+// wired to another task (see Input.From) is among the given files,
+// which are the files that will be available to the task. It returns
+// an error naming every input that is missing.
 func ValidateInputs(task Task, inFiles []files.Path) error {
+	available := map[string]bool{}
+	for _, f := range inFiles {
+		available[f.File()] = true
+	}
+
+	var missing []string
+	for _, in := range task.Inputs {
+		if in.From != nil || available[in.File()] {
+			continue
+		}
+		missing = append(missing, string(in.Name))
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no file available for inputs %s", strings.Join(missing, ", "))
+	}
+
 	return nil
 }
@@ -0,0 +1,127 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func validTask(name string, inputs []Input, outputs []files.Path) Task {
+	return Task{
+		Name:    name,
+		Cmd:     []string{"true"},
+		Image:   "debian:bookworm-slim",
+		Inputs:  inputs,
+		Outputs: outputs,
+	}
+}
+
+// fields returns the task index and field of each diagnostic, to make
+// them easier to compare.
+func fields(diags Diagnostics) []string {
+	var fs []string
+	for _, d := range diags {
+		fs = append(fs, fmt.Sprintf("%s %d %s", d.Severity, d.Task, d.Field))
+	}
+
+	return fs
+}
+
+func TestValidator_Workflow(t *testing.T) {
+	v, err := NewValidator()
+	assert.NoError(t, err)
+
+	t.Run("should accept a valid workflow", func(t *testing.T) {
+		w := Workflow{Tasks: TaskSet{
+			validTask("a", []Input{{Name: "in.txt"}}, []files.Path{"a.txt"}),
+			validTask("b", []Input{{Name: "a.txt"}}, []files.Path{"b.txt"}),
+			validTask("c", []Input{{Name: "x.txt", From: &Source{Task: "a", Output: "a.txt"}}}, nil),
+		}}
+
+		assert.Equal(t, Diagnostics(nil), v.Workflow(w))
+	})
+
+	t.Run("should report every problem", func(t *testing.T) {
+		w := Workflow{Tasks: TaskSet{
+			{
+				Name:    "a",
+				WorkDir: "work",
+				Inputs:  []Input{{Name: "in.txt"}, {Name: "dir/in.txt"}},
+				Outputs: []files.Path{"in.txt", "in.txt"},
+			},
+			validTask("a", []Input{{Name: "missing.txt"}}, nil),
+			validTask("c", []Input{{Name: "x.txt", From: &Source{Task: "b", Output: "a.txt"}}}, nil),
+		}}
+
+		diags := v.Workflow(w)
+		assert.Equal(t, []string{
+			"error 0 cmd",
+			"error 0 image",
+			"error 0 workdir",
+			"error 0 inputs[1]",
+			"warning 0 outputs[0]",
+			"error 0 outputs[1]",
+			"error 1 name",
+			"error 2 inputs[0]",
+			"error 1 inputs",
+		}, fields(diags))
+
+		err := diags.Err()
+		var verr *ValidationError
+		assert.True(t, errors.As(err, &verr))
+		assert.Equal(t, 8, len(verr.Diagnostics))
+		assert.Contains(t, err.Error(), "error: task 2 (c): inputs[0]: input x.txt: no earlier task named b")
+	})
+
+	t.Run("should report invalid filters", func(t *testing.T) {
+		w := Workflow{
+			Include: []Filter{{Ext: "txt"}, {Path: `(`}},
+			Exclude: []Filter{{ModifiedSince: "yesterday"}},
+			Tasks:   TaskSet{validTask("a", nil, nil)},
+		}
+
+		assert.Equal(t, []string{
+			"error -1 include[1]",
+			"error -1 exclude[0]",
+		}, fields(v.Workflow(w)))
+	})
+
+	t.Run("should warn about inputs from further back", func(t *testing.T) {
+		w := Workflow{Tasks: TaskSet{
+			validTask("a", []Input{{Name: "in.txt"}}, []files.Path{"a.txt"}),
+			validTask("b", []Input{{Name: "a.txt"}}, []files.Path{"b.txt"}),
+			validTask("c", []Input{{Name: "in.txt"}}, nil),
+		}}
+
+		diags := v.Workflow(w)
+		assert.Equal(t, []string{"warning 2 inputs[0]"}, fields(diags))
+		assert.NoError(t, diags.Err())
+	})
+
+	t.Run("should allow tasks without images when asked", func(t *testing.T) {
+		v, err := NewValidator(WithoutImages())
+		assert.NoError(t, err)
+
+		task := validTask("a", nil, nil)
+		task.Image = ""
+
+		assert.Equal(t, Diagnostics(nil), v.Task(task))
+	})
+}
+
+func TestValidateInputs(t *testing.T) {
+	task := validTask("a", []Input{
+		{Name: "a.txt"},
+		{Name: "b.txt"},
+		{Name: "c.txt", From: &Source{Task: "x", Output: "y.txt"}},
+	}, nil)
+
+	assert.NoError(t, ValidateInputs(task, []files.Path{"/data/a.txt", "b.txt"}))
+
+	err := ValidateInputs(task, []files.Path{"a.txt"})
+	assert.EqualError(t, err, "no file available for inputs b.txt")
+}
@@ -1,47 +1,73 @@
 package spec
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
-	"os"
+
+	"github.com/glesica/flowork/internal/pkg/files"
 )
 
+// Workflow is a sequence of tasks that is run, as a job, for each of
+// the workflow inputs.
 type Workflow struct {
 	// Name is the workflow name, used for UI purposes only.
-	Name string `json:"name"`
+	Name string `json:"name" toml:"name"`
 
 	// Desc is a description of the workflow, intended for
 	// UI and documentation purposes.
-	Desc string `json:"desc"`
+	Desc string `json:"desc" toml:"desc,omitempty"`
+
+	// Params declares the parameters of the workflow, by name, which
+	// tasks can use in templates (see TemplateData) and which can be
+	// set when the workflow is run.
+	Params map[string]Param `json:"params,omitempty" toml:"params,omitempty"`
+
+	// Include selects the workflow inputs to use. An input is used if
+	// it matches any of the filters. By default, every input is used.
+	Include []Filter `json:"include,omitempty" toml:"include,omitempty"`
+
+	// Exclude selects workflow inputs to skip, even if they match
+	// Include.
+	Exclude []Filter `json:"exclude,omitempty" toml:"exclude,omitempty"`
 
 	// Tasks is the list of tasks to execute when the workflow
 	// is run.
-	Tasks TaskSet `json:"tasks"`
+	Tasks TaskSet `json:"tasks" toml:"tasks"`
+
+	// KeepIntermediates disables the removal of intermediate files
+	// between tasks. By default, after each task runs, any files that
+	// aren't declared as inputs of later tasks, or as outputs of the
+	// final task, are deleted to save space.
+	KeepIntermediates bool `json:"keep_intermediates" toml:"keep_intermediates,omitempty"`
+
+	// IsolateTasks causes each task to run in its own volume, which
+	// contains only the inputs the task declares, rather than having
+	// all the tasks in a job share one volume. This uses more space,
+	// but prevents tasks from interfering with each other's files.
+	IsolateTasks bool `json:"isolate_tasks" toml:"isolate_tasks,omitempty"`
 }
 
+// LoadWorkflowPath loads a workflow from a local file, in any of the
+// supported formats. The format is determined by the file extension
+// or, failing that, by the contents of the file. Task references (see
+// TaskRef) are resolved relative to the file. Use a Loader to load
+// workflows from other stores.
 func LoadWorkflowPath(p string) (Workflow, error) {
-	f, err := os.Open(p)
-	if err != nil {
-		return Workflow{}, fmt.Errorf("failed to open workflow file (%s): %w", p, err)
-	}
-	defer func() { _ = f.Close() }()
-
-	return LoadWorkflow(f)
+	return NewLoader(&files.Local{}).Workflow(files.Path(p))
 }
 
+// LoadWorkflow loads a workflow in any of the supported formats, which
+// is determined by the contents. Task references are resolved relative
+// to the working directory.
 func LoadWorkflow(data io.Reader) (Workflow, error) {
-	w := Workflow{}
-
 	raw, err := io.ReadAll(data)
 	if err != nil {
-		return w, fmt.Errorf("failed to load workflow from file: %w", err)
+		return Workflow{}, fmt.Errorf("failed to load workflow from file: %w", err)
 	}
 
-	err = json.Unmarshal(raw, &w)
-	if err != nil {
-		return w, fmt.Errorf("failed to parse workflow from JSON: %w", err)
-	}
+	return loadWorkflow(raw, sniff(raw))
+}
 
-	return w, nil
+func loadWorkflow(raw []byte, f Format) (Workflow, error) {
+	return NewLoader(&files.Local{}).workflow(raw, f, "")
 }
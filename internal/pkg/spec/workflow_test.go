@@ -0,0 +1,64 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestLoadWorkflow(t *testing.T) {
+	t.Run("should load bare and wired inputs", func(t *testing.T) {
+		w, err := LoadWorkflow(strings.NewReader(`{
+			"tasks": [
+				{"name": "parse", "inputs": ["raw.csv"], "outputs": ["parsed.csv"]},
+				{"name": "train", "inputs": [
+					{"name": "data.csv", "from": {"task": "parse", "output": "parsed.csv"}}
+				]}
+			]
+		}`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, Input{Name: "raw.csv"}, w.Tasks[0].Inputs[0])
+		assert.Equal(t, Input{
+			Name: "data.csv",
+			From: &Source{Task: "parse", Output: "parsed.csv"},
+		}, w.Tasks[1].Inputs[0])
+	})
+
+	t.Run("should reject wiring to a missing output", func(t *testing.T) {
+		_, err := LoadWorkflow(strings.NewReader(`{
+			"tasks": [
+				{"name": "parse", "outputs": ["parsed.csv"]},
+				{"name": "train", "inputs": [
+					{"name": "data.csv", "from": {"task": "parse", "output": "other.csv"}}
+				]}
+			]
+		}`))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "task parse has no output other.csv")
+	})
+
+	t.Run("should reject wiring to a later task", func(t *testing.T) {
+		_, err := LoadWorkflow(strings.NewReader(`{
+			"tasks": [
+				{"name": "parse", "inputs": [
+					{"name": "data.csv", "from": {"task": "train", "output": "model.bin"}}
+				]},
+				{"name": "train", "outputs": ["model.bin"]}
+			]
+		}`))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no earlier task named train")
+	})
+}
+
+func TestInput_MarshalJSON(t *testing.T) {
+	bare, err := Input{Name: "a.txt"}.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `"a.txt"`, string(bare))
+
+	wired, err := Input{Name: "a.txt", From: &Source{Task: "t", Output: "b.txt"}}.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"a.txt","from":{"task":"t","output":"b.txt"}}`, string(wired))
+}
@@ -1,14 +1,88 @@
 package task
 
 import (
+	"fmt"
 	"log/slog"
 )
 
-func DockerRun(inst *Instance, v Volume, user string) ([]string, error) {
+// A Runtime knows how to build the command line used to run a task
+// instance inside a container using a particular container runtime,
+// such as Docker or Podman.
+type Runtime interface {
+	// Name returns the name of the runtime, which is also the name
+	// used to select it from the command line.
+	Name() string
+
+	// RunCommand returns the argv array that will run the given task
+	// instance in a container with the given volume mounted as its
+	// working directory. The user is the ID of the user that should
+	// own any files the task creates.
+	RunCommand(inst *Instance, v Volume, user string) ([]string, error)
+}
+
+// Runtimes returns all of the supported container runtimes.
+func Runtimes() []Runtime {
+	return []Runtime{Docker{}, Podman{}, Nerdctl{}}
+}
+
+// RuntimeFor returns the container runtime with the given name.
+func RuntimeFor(name string) (Runtime, error) {
+	for _, rt := range Runtimes() {
+		if rt.Name() == name {
+			return rt, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported container runtime (%s)", name)
+}
+
+// Docker runs containers using the Docker CLI.
+type Docker struct{}
+
+func (Docker) Name() string {
+	return "docker"
+}
+
+func (rt Docker) RunCommand(inst *Instance, v Volume, user string) ([]string, error) {
+	// Set user:group (-u)
+	return containerRun(rt.Name(), inst, v, "-u", user+":"+user), nil
+}
+
+// Podman runs containers using Podman, which is generally rootless.
+// Instead of setting the container user explicitly, the current user
+// is mapped into the container so that files written to the volume
+// belong to them on the host.
+type Podman struct{}
+
+func (Podman) Name() string {
+	return "podman"
+}
+
+func (rt Podman) RunCommand(inst *Instance, v Volume, user string) ([]string, error) {
+	return containerRun(rt.Name(), inst, v, "--userns=keep-id"), nil
+}
+
+// Nerdctl runs containers using the containerd CLI, which accepts
+// the same flags as Docker.
+type Nerdctl struct{}
+
+func (Nerdctl) Name() string {
+	return "nerdctl"
+}
+
+func (rt Nerdctl) RunCommand(inst *Instance, v Volume, user string) ([]string, error) {
+	// Set user:group (-u)
+	return containerRun(rt.Name(), inst, v, "-u", user+":"+user), nil
+}
+
+// containerRun builds a Docker-compatible run command using the
+// given executable, with the given flags used to set the user that
+// the task will run as.
+func containerRun(exe string, inst *Instance, v Volume, userFlags ...string) []string {
 	containerWorkDir := inst.GetWorkDir()
 
 	command := []string{
-		"docker",
+		exe,
 		"run",
 		"--rm",
 		// "--read-only",
@@ -20,8 +94,7 @@ func DockerRun(inst *Instance, v Volume, user string) ([]string, error) {
 	// Set working directory (-w)
 	command = append(command, "-w", containerWorkDir)
 
-	// Set user:group (-u)
-	command = append(command, "-u", user+":"+user)
+	command = append(command, userFlags...)
 
 	// Set environment variables (-e)
 	// TODO: Implement environment variables
@@ -34,5 +107,5 @@ func DockerRun(inst *Instance, v Volume, user string) ([]string, error) {
 
 	slog.Debug("running command", "command", command)
 
-	return command, nil
+	return command
 }
@@ -0,0 +1,52 @@
+package task
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRuntime_RunCommand(t *testing.T) {
+	inst, err := NewInstance(spec.Task{
+		Name:  "list",
+		Cmd:   []string{"ls", "-l", "/work"},
+		Image: "debian:bookworm-slim",
+	})
+	assert.NoError(t, err)
+
+	for _, rt := range Runtimes() {
+		t.Run(rt.Name(), func(t *testing.T) {
+			command, err := rt.RunCommand(inst, "/host/volume", "1000")
+			assert.NoError(t, err)
+
+			actual := strings.Join(command, "\n") + "\n"
+			golden := filepath.Join("fixtures", rt.Name()+".golden")
+
+			if *update {
+				err := os.WriteFile(golden, []byte(actual), 0644)
+				assert.NoError(t, err)
+			}
+
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), actual)
+		})
+	}
+}
+
+func TestRuntimeFor(t *testing.T) {
+	rt, err := RuntimeFor("podman")
+	assert.NoError(t, err)
+	assert.Equal(t, "podman", rt.Name())
+
+	_, err = RuntimeFor("lxc")
+	assert.Error(t, err)
+}
@@ -1,18 +1,16 @@
 package task
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"os/user"
-	"path/filepath"
 
-	"github.com/glesica/flowork/internal/app/options"
 	"github.com/glesica/flowork/internal/pkg/files"
-	"github.com/glesica/flowork/internal/pkg/id"
-	"github.com/glesica/flowork/internal/pkg/shell"
 )
 
+// DockerRunner runs each task in a container on the local machine,
+// using a Docker-compatible container runtime.
 type DockerRunner struct {
 	// Debug indicates whether debug mode is enabled. In debug mode,
 	// the runner doesn't delete volumes and may provide additional
@@ -28,70 +26,77 @@ type DockerRunner struct {
 	// Store is the store to be used for reading and writing files
 	// to volumes.
 	Store files.Store
+
+	// Runtime is the container runtime used to run tasks. If it is
+	// nil, Docker will be used.
+	Runtime Runtime
+
+	// PoolSize is the maximum number of volumes that will be kept,
+	// once cleared, to be reused instead of being deleted. When a
+	// volume is requested, one from the pool is used, if possible.
+	// Pooling is disabled if it is zero, or in debug mode. Call Close
+	// to delete any pooled volumes once the runner is no longer needed.
+	PoolSize int
+
+	pool volumePool
 }
 
 func (r *DockerRunner) CreateVolume(s files.Size) (Volume, error) {
-	volDir := filepath.Join(string(r.WorkDir), options.VolumesDirName, id.New())
-
-	err := os.MkdirAll(volDir, 0777)
-	if err != nil {
-		return "", fmt.Errorf("failed to create volume (%s): %w", volDir, err)
+	if v, ok := r.pool.get(); ok {
+		slog.Debug("reusing pooled volume", "volume", v)
+		return v, nil
 	}
 
-	return Volume(volDir), nil
+	return createLocalVolume(r.WorkDir)
 }
 
 func (r *DockerRunner) DeleteVolume(v Volume) error {
-	if r.Debug {
-		slog.Debug("delete volume requested, ignoring", "volume", v)
-		return nil
-	}
-
-	err := os.RemoveAll(string(v))
-	if err != nil {
-		return fmt.Errorf("failed to delete volume %s: %w", v, err)
+	if r.PoolSize > 0 && !r.Debug {
+		err := r.ClearVolume(v)
+		if err != nil {
+			slog.Warn("failed to clear volume for reuse", "volume", v, "error", err)
+		} else if r.pool.put(v, r.PoolSize) {
+			slog.Debug("returned volume to pool", "volume", v)
+			return nil
+		}
 	}
 
-	return nil
+	return deleteLocalVolume(v, r.Debug)
 }
 
-// TODO: Make name a path and create intermediate directories
+// ClearVolume deletes the contents of the given volume.
+func (r *DockerRunner) ClearVolume(v Volume) error {
+	return pruneLocalVolume(v, nil, false)
+}
 
-func (r *DockerRunner) AddFile(s files.Path, v Volume, name string) error {
-	fileData, err := r.Store.Load(s)
-	if err != nil {
-		return fmt.Errorf("failed to load file %s for add: %w", s, err)
+// Close deletes any volumes being held for reuse.
+func (r *DockerRunner) Close() error {
+	var errs []error
+	for _, v := range r.pool.drain() {
+		errs = append(errs, deleteLocalVolume(v, false))
 	}
-	defer func() { _ = fileData.Close() }()
 
-	dest := filepath.Join(string(v), name)
-
-	err = r.Store.Save(files.Path(dest), fileData)
-	if err != nil {
-		return fmt.Errorf("failed to save file %s for add: %w", dest, err)
-	}
+	return errors.Join(errs...)
+}
 
-	return nil
+func (r *DockerRunner) AddFile(s files.Path, v Volume, name string) error {
+	return addLocalFile(r.Store, s, v, name)
 }
 
 func (r *DockerRunner) ExtractFile(s files.Path, v Volume, d files.Dir) error {
-	name := s.File()
-	src := filepath.Join(string(v), name)
-
-	fileData, err := r.Store.Load(files.Path(src))
-	if err != nil {
-		return fmt.Errorf("failed to load file %s for extract: %w", s, err)
-	}
-	defer func() { _ = fileData.Close() }()
+	return extractLocalFile(r.Store, s, v, d)
+}
 
-	dest := d.PathTo(name)
+func (r *DockerRunner) LinkFile(sv Volume, src string, dv Volume, dest string) error {
+	return linkLocalFile(sv, src, dv, dest)
+}
 
-	err = r.Store.Save(dest, fileData)
-	if err != nil {
-		return fmt.Errorf("failed to save file %s for extract: %w", d, err)
-	}
+func (r *DockerRunner) PruneVolume(v Volume, keep []string) error {
+	return pruneLocalVolume(v, keep, r.Debug)
+}
 
-	return nil
+func (r *DockerRunner) Env() files.Env {
+	return files.EnvLocal
 }
 
 func (r *DockerRunner) Run(inst *Instance, v Volume) error {
@@ -100,35 +105,25 @@ func (r *DockerRunner) Run(inst *Instance, v Volume) error {
 		return fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	command, err := DockerRun(inst, v, currentUser.Uid)
-	if err != nil {
-		return fmt.Errorf("failed to build docker command: %w", err)
-	}
+	rt := r.runtime()
 
-	result, err := shell.Run(command)
+	command, err := rt.RunCommand(inst, v, currentUser.Uid)
 	if err != nil {
-		if result != nil {
-			_ = writeOutput(string(v), "stdout.txt", result.Out)
-			_ = writeOutput(string(v), "stderr.txt", result.Err)
-		}
-		return fmt.Errorf("failed to run docker (%v): %w", command, err)
+		return fmt.Errorf("failed to build %s command: %w", rt.Name(), err)
 	}
 
-	// TODO: Write to workflow and task instance specific directories
-
-	err = writeOutput(string(v), "stdout.txt", result.Out)
+	_, err = streamCommand(inst, "", command)
 	if err != nil {
-		return fmt.Errorf("failed to write stdout.txt: %w", err)
+		return fmt.Errorf("failed to run %s (%v): %w", rt.Name(), command, err)
 	}
 
-	err = writeOutput(string(v), "stderr.txt", result.Err)
-	if err != nil {
-		return fmt.Errorf("failed to write stderr.txt: %w", err)
-	}
+	return nil
+}
 
-	if result.Code != 0 {
-		return fmt.Errorf("task failed, see stderr.txt")
+func (r *DockerRunner) runtime() Runtime {
+	if r.Runtime == nil {
+		return Docker{}
 	}
 
-	return nil
+	return r.Runtime
 }
@@ -0,0 +1,518 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// DefaultDockerHost is the Docker Engine API address used when
+// neither DockerApiRunner.Host nor DOCKER_HOST are set.
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// DockerApiRunner runs each task in a container on the local machine
+// by talking directly to the Docker Engine API, rather than by
+// running the docker executable. This allows it to track container
+// IDs, stream logs as they are produced, and recover exit codes and
+// out-of-memory kills.
+type DockerApiRunner struct {
+	// Debug indicates whether debug mode is enabled. In debug mode,
+	// the runner doesn't delete volumes or containers and may provide
+	// additional output.
+	Debug bool
+
+	// WorkDir is the local (host) working directory, used to store
+	// data for tasks to operate on. Subdirectories will be created
+	// and mounted as volumes in the container that is created to
+	// run each task.
+	WorkDir files.Dir
+
+	// Store is the store to be used for reading and writing files
+	// to volumes.
+	Store files.Store
+
+	// Host is the address of the Docker Engine API, either a unix
+	// socket (unix:///path/to/docker.sock) or an HTTP URL. If it is
+	// empty, DOCKER_HOST is used, followed by DefaultDockerHost.
+	Host string
+
+	once    sync.Once
+	client  *http.Client
+	baseUrl string
+
+	lock       sync.Mutex
+	containers map[string]string
+}
+
+// ContainerError is returned when a task container exits
+// unsuccessfully.
+type ContainerError struct {
+	// ContainerID is the Docker ID of the container that failed.
+	ContainerID string
+
+	// ExitCode is the exit code of the task command.
+	ExitCode int
+
+	// OOMKilled indicates that the container was killed because it
+	// ran out of memory.
+	OOMKilled bool
+
+	// Message is an error message reported by Docker, if any.
+	Message string
+}
+
+func (e *ContainerError) Error() string {
+	msg := fmt.Sprintf("container %s exited with code %d", e.ContainerID, e.ExitCode)
+	if e.OOMKilled {
+		msg += " (out of memory)"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+func (r *DockerApiRunner) CreateVolume(s files.Size) (Volume, error) {
+	return createLocalVolume(r.WorkDir)
+}
+
+func (r *DockerApiRunner) DeleteVolume(v Volume) error {
+	return deleteLocalVolume(v, r.Debug)
+}
+
+func (r *DockerApiRunner) AddFile(s files.Path, v Volume, name string) error {
+	return addLocalFile(r.Store, s, v, name)
+}
+
+func (r *DockerApiRunner) ExtractFile(s files.Path, v Volume, d files.Dir) error {
+	return extractLocalFile(r.Store, s, v, d)
+}
+
+func (r *DockerApiRunner) LinkFile(sv Volume, src string, dv Volume, dest string) error {
+	return linkLocalFile(sv, src, dv, dest)
+}
+
+func (r *DockerApiRunner) PruneVolume(v Volume, keep []string) error {
+	return pruneLocalVolume(v, keep, r.Debug)
+}
+
+// ClearVolume deletes the contents of the given volume.
+func (r *DockerApiRunner) ClearVolume(v Volume) error {
+	return pruneLocalVolume(v, nil, false)
+}
+
+func (r *DockerApiRunner) Env() files.Env {
+	return files.EnvLocal
+}
+
+// ContainerID returns the ID of the container currently running the
+// given task instance, if there is one.
+func (r *DockerApiRunner) ContainerID(inst *Instance) (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cid, ok := r.containers[inst.ID]
+	return cid, ok
+}
+
+// Kill stops the container running the given task instance, if
+// there is one. The task will fail.
+func (r *DockerApiRunner) Kill(inst *Instance) error {
+	cid, ok := r.ContainerID(inst)
+	if !ok {
+		return fmt.Errorf("no container running task instance %s", inst.ID)
+	}
+
+	resp, err := r.do(http.MethodPost, "/containers/"+cid+"/kill", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to kill container %s: %w", cid, err)
+	}
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+func (r *DockerApiRunner) Run(inst *Instance, v Volume) error {
+	currentUser, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	cid, err := r.createContainer(inst, v, currentUser.Uid)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("created container", "container", cid, "name", inst.Name, "id", inst.ID)
+
+	r.track(inst, cid)
+	defer r.untrack(inst)
+
+	defer func() {
+		if r.Debug {
+			slog.Debug("remove container requested, ignoring", "container", cid)
+			return
+		}
+
+		err := r.removeContainer(cid)
+		if err != nil {
+			slog.Error("failed to remove container", "container", cid, "error", err)
+		}
+	}()
+
+	resp, err := r.do(http.MethodPost, "/containers/"+cid+"/start", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start container %s: %w", cid, err)
+	}
+	_ = resp.Body.Close()
+
+	err = r.streamLogs(cid, inst)
+	if err != nil {
+		return err
+	}
+
+	return r.waitContainer(cid, inst)
+}
+
+type containerConfig struct {
+	Image      string
+	Cmd        []string
+	WorkingDir string
+	User       string
+	HostConfig hostConfig
+}
+
+type hostConfig struct {
+	Binds []string
+}
+
+func (r *DockerApiRunner) createContainer(inst *Instance, v Volume, uid string) (string, error) {
+	workDir := inst.GetWorkDir()
+	config := containerConfig{
+		Image:      inst.Image,
+		Cmd:        inst.Cmd,
+		WorkingDir: workDir,
+		User:       uid + ":" + uid,
+		HostConfig: hostConfig{
+			Binds: []string{string(v) + ":" + workDir},
+		},
+	}
+
+	var created struct {
+		Id       string
+		Warnings []string
+	}
+
+	err := r.doJson(http.MethodPost, "/containers/create", config, &created)
+
+	var apiErr *dockerApiError
+	if errors.As(err, &apiErr) && apiErr.status == http.StatusNotFound {
+		// The image isn't available locally, so we pull it and try
+		// one more time.
+		err = r.pullImage(inst.Image)
+		if err != nil {
+			return "", err
+		}
+
+		err = r.doJson(http.MethodPost, "/containers/create", config, &created)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create container for %s: %w", inst.Image, err)
+	}
+
+	for _, w := range created.Warnings {
+		slog.Warn("docker warning", "container", created.Id, "warning", w)
+	}
+
+	return created.Id, nil
+}
+
+func (r *DockerApiRunner) pullImage(image string) error {
+	slog.Info("pulling image", "image", image)
+
+	name, tag := splitImage(image)
+	query := url.Values{"fromImage": {name}, "tag": {tag}}
+	resp, err := r.do(http.MethodPost, "/images/create", query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// The pull is complete once the progress stream ends, but errors
+	// are reported in-band.
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var progress struct {
+			Error string `json:"error"`
+		}
+		err := dec.Decode(&progress)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read pull progress for %s: %w", image, err)
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", image, progress.Error)
+		}
+	}
+}
+
+// splitImage splits an image reference into the repository and the
+// tag or digest, as the pull endpoint expects them. A digest wins over
+// a tag, since it pins the image exactly, and the tag defaults to
+// "latest".
+func splitImage(image string) (name, tag string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		name, tag = image[:i], image[i+1:]
+		if j := strings.LastIndex(name, ":"); j > strings.LastIndex(name, "/") {
+			name = name[:j]
+		}
+		return name, tag
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+
+	return image, "latest"
+}
+
+// streamLogs follows the container logs until the container exits,
+// streaming them into the instance's writers.
+func (r *DockerApiRunner) streamLogs(cid string, inst *Instance) error {
+	stdout, stderr := instanceWriters(inst)
+
+	query := url.Values{"follow": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	resp, err := r.do(http.MethodGet, "/containers/"+cid+"/logs", query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get logs for container %s: %w", cid, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	err = demuxLogs(resp.Body, stdout, stderr)
+	if err != nil {
+		return fmt.Errorf("failed to read logs for container %s: %w", cid, err)
+	}
+
+	return nil
+}
+
+// demuxLogs splits a multiplexed Docker log stream into its stdout
+// and stderr components. Each frame has an 8 byte header, the first
+// byte is the stream (1 for stdout, 2 for stderr) and the last four
+// are the big-endian length of the payload that follows.
+func demuxLogs(src io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(src, header)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var dest io.Writer
+		switch header[0] {
+		case 1:
+			dest = stdout
+		case 2:
+			dest = stderr
+		default:
+			dest = io.Discard
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		_, err = io.CopyN(dest, src, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *DockerApiRunner) waitContainer(cid string, inst *Instance) error {
+	var waited struct {
+		StatusCode int
+		Error      *struct {
+			Message string
+		}
+	}
+
+	err := r.doJson(http.MethodPost, "/containers/"+cid+"/wait", nil, &waited)
+	if err != nil {
+		return fmt.Errorf("failed to wait for container %s: %w", cid, err)
+	}
+
+	var inspected struct {
+		State struct {
+			OOMKilled bool
+		}
+	}
+
+	err = r.doJson(http.MethodGet, "/containers/"+cid+"/json", nil, &inspected)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", cid, err)
+	}
+
+	inst.Result.ExitCode = waited.StatusCode
+
+	if waited.StatusCode == 0 && !inspected.State.OOMKilled {
+		return nil
+	}
+
+	cerr := &ContainerError{
+		ContainerID: cid,
+		ExitCode:    waited.StatusCode,
+		OOMKilled:   inspected.State.OOMKilled,
+	}
+	if waited.Error != nil {
+		cerr.Message = waited.Error.Message
+	}
+
+	return cerr
+}
+
+func (r *DockerApiRunner) removeContainer(cid string) error {
+	query := url.Values{"force": {"1"}}
+	resp, err := r.do(http.MethodDelete, "/containers/"+cid, query, nil)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+func (r *DockerApiRunner) track(inst *Instance, cid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.containers == nil {
+		r.containers = map[string]string{}
+	}
+	r.containers[inst.ID] = cid
+}
+
+func (r *DockerApiRunner) untrack(inst *Instance) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.containers, inst.ID)
+}
+
+// dockerApiError is returned when the Docker Engine API responds
+// with an error status.
+type dockerApiError struct {
+	status  int
+	message string
+}
+
+func (e *dockerApiError) Error() string {
+	return fmt.Sprintf("docker api error (%d): %s", e.status, e.message)
+}
+
+func (r *DockerApiRunner) init() {
+	host := r.Host
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = DefaultDockerHost
+	}
+
+	if strings.HasPrefix(host, "unix://") {
+		socket := strings.TrimPrefix(host, "unix://")
+		r.client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+		// The host name is ignored when dialing a socket.
+		r.baseUrl = "http://docker"
+		return
+	}
+
+	r.client = http.DefaultClient
+	r.baseUrl = strings.TrimSuffix(strings.Replace(host, "tcp://", "http://", 1), "/")
+}
+
+func (r *DockerApiRunner) do(method, path string, query url.Values, body any) (*http.Response, error) {
+	r.once.Do(r.init)
+
+	u := r.baseUrl + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer func() { _ = resp.Body.Close() }()
+
+		var msg struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&msg)
+
+		return nil, &dockerApiError{status: resp.StatusCode, message: msg.Message}
+	}
+
+	return resp, nil
+}
+
+func (r *DockerApiRunner) doJson(method, path string, body any, result any) error {
+	resp, err := r.do(method, path, nil, body)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
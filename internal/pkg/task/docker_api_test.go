@@ -0,0 +1,195 @@
+package task
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+// logFrame builds a single frame of a multiplexed Docker log stream.
+func logFrame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// fakeDocker is a minimal stand-in for the Docker Engine API that
+// runs a single container with a fixed result.
+type fakeDocker struct {
+	exitCode  int
+	oomKilled bool
+	haveImage bool
+
+	lock     sync.Mutex
+	requests []string
+	created  map[string]any
+}
+
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	d.lock.Lock()
+	d.requests = append(d.requests, req.Method+" "+req.URL.Path)
+	d.lock.Unlock()
+
+	switch {
+	case req.URL.Path == "/containers/create":
+		if !d.haveImage {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "no such image"})
+			return
+		}
+		_ = json.NewDecoder(req.Body).Decode(&d.created)
+		_ = json.NewEncoder(w).Encode(map[string]any{"Id": "abc123"})
+	case req.URL.Path == "/images/create":
+		d.haveImage = true
+		_, _ = w.Write([]byte(`{"status":"Pulling"}` + "\n"))
+	case strings.HasSuffix(req.URL.Path, "/start"):
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(req.URL.Path, "/logs"):
+		_, _ = w.Write(logFrame(1, "out\n"))
+		_, _ = w.Write(logFrame(2, "err\n"))
+	case strings.HasSuffix(req.URL.Path, "/wait"):
+		_ = json.NewEncoder(w).Encode(map[string]any{"StatusCode": d.exitCode})
+	case strings.HasSuffix(req.URL.Path, "/json"):
+		_ = json.NewEncoder(w).Encode(map[string]any{"State": map[string]any{"OOMKilled": d.oomKilled}})
+	case req.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// saw indicates whether the given request (method and path) was
+// received by the fake.
+func (d *fakeDocker) saw(request string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, r := range d.requests {
+		if r == request {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runFakeDocker(t *testing.T, d *fakeDocker) (*DockerApiRunner, Volume, *Instance) {
+	server := httptest.NewServer(d)
+	t.Cleanup(server.Close)
+
+	r := &DockerApiRunner{
+		WorkDir: files.Dir(t.TempDir()),
+		Store:   &files.Local{},
+		Host:    server.URL,
+	}
+
+	v, err := r.CreateVolume(0)
+	assert.NoError(t, err)
+
+	inst, err := NewInstance(spec.Task{
+		Name:  "echo",
+		Cmd:   []string{"echo", "out"},
+		Image: "debian:bookworm-slim",
+	}, WithCapture(&files.Local{}, files.Dir(t.TempDir())))
+	assert.NoError(t, err)
+
+	return r, v, inst
+}
+
+func TestDockerApiRunner_Run(t *testing.T) {
+	t.Run("should stream logs and remove the container", func(t *testing.T) {
+		d := &fakeDocker{haveImage: true}
+		r, v, inst := runFakeDocker(t, d)
+
+		err := r.Run(inst, v)
+		assert.NoError(t, err)
+		assert.NoError(t, inst.Finalize())
+
+		captureDir, _ := inst.CaptureDir()
+
+		stdout, err := os.ReadFile(filepath.Join(string(captureDir), "stdout.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "out\n", string(stdout))
+
+		stderr, err := os.ReadFile(filepath.Join(string(captureDir), "stderr.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "err\n", string(stderr))
+
+		assert.True(t, d.saw("DELETE /containers/abc123"))
+		assert.Equal(t, "/work", d.created["WorkingDir"])
+
+		_, running := r.ContainerID(inst)
+		assert.False(t, running)
+	})
+
+	t.Run("should pull a missing image", func(t *testing.T) {
+		d := &fakeDocker{}
+		r, v, inst := runFakeDocker(t, d)
+
+		err := r.Run(inst, v)
+		assert.NoError(t, err)
+		assert.True(t, d.saw("POST /images/create"))
+	})
+
+	t.Run("should report a failed container", func(t *testing.T) {
+		d := &fakeDocker{haveImage: true, exitCode: 137, oomKilled: true}
+		r, v, inst := runFakeDocker(t, d)
+
+		err := r.Run(inst, v)
+
+		var cerr *ContainerError
+		assert.True(t, errors.As(err, &cerr))
+		assert.Equal(t, "abc123", cerr.ContainerID)
+		assert.Equal(t, 137, cerr.ExitCode)
+		assert.True(t, cerr.OOMKilled)
+		assert.Equal(t, 137, inst.Result.ExitCode)
+		assert.True(t, d.saw("DELETE /containers/abc123"))
+	})
+}
+
+func Test_demuxLogs(t *testing.T) {
+	var stream []byte
+	stream = append(stream, logFrame(1, "one")...)
+	stream = append(stream, logFrame(2, "two")...)
+	stream = append(stream, logFrame(1, "three")...)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err := demuxLogs(bytes.NewReader(stream), stdout, stderr)
+	assert.NoError(t, err)
+	assert.Equal(t, "onethree", stdout.String())
+	assert.Equal(t, "two", stderr.String())
+}
+
+func Test_splitImage(t *testing.T) {
+	for _, tc := range []struct {
+		image, name, tag string
+	}{
+		{"debian", "debian", "latest"},
+		{"debian:bookworm", "debian", "bookworm"},
+		{"localhost:5000/tools/app", "localhost:5000/tools/app", "latest"},
+		{"localhost:5000/tools/app:1.2", "localhost:5000/tools/app", "1.2"},
+		{"repo@sha256:abc123", "repo", "sha256:abc123"},
+		{"repo:1.2@sha256:abc123", "repo", "sha256:abc123"},
+		{"localhost:5000/repo@sha256:abc123", "localhost:5000/repo", "sha256:abc123"},
+	} {
+		t.Run(tc.image, func(t *testing.T) {
+			name, tag := splitImage(tc.image)
+			assert.Equal(t, tc.name, name)
+			assert.Equal(t, tc.tag, tag)
+		})
+	}
+}
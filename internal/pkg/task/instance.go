@@ -1,28 +1,72 @@
 package task
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/files/output"
 	"github.com/glesica/flowork/internal/pkg/id"
 	"github.com/glesica/flowork/internal/pkg/option"
 	"github.com/glesica/flowork/internal/pkg/spec"
 )
 
+// MetadataFileName is the name of the file, written alongside the
+// captured output of a task instance, that describes how it ran.
+const MetadataFileName = "metadata.json"
+
 // An Instance provides everything necessary for a runner to interact
-// with a particular task in a structured way. It is mutable, and
-// should only be submitted to a runner once, for this reason. Once
-// it has passed through a runner, the workflow runtime will call
-// Finalize(), after which it becomes read-only.
+// with a particular task in a structured way. It is mutable, so it
+// should only be submitted to one runner at a time. The workflow
+// runtime calls Finalize() after every attempt to run it.
+//
+// A failed instance may be run again, when its job is retried. Each
+// attempt starts with a fresh Result, and replaces the output captured
+// by the previous attempt, along with its metadata. The writers given
+// with WithStdout and WithStderr are closed after every attempt, so
+// they must remain usable once closed (see output.Prefix).
 type Instance struct {
 	spec.Task
 
 	// ID is the unique identifier for the task instance.
 	ID string `json:"id"`
 
+	// Attempts is the number of times the task instance has been run.
+	Attempts int `json:"attempts"`
+
+	// Result describes the outcome of running the task instance. It
+	// is filled in by the workflow runtime and the runner. If the
+	// instance is run more than once, it describes the latest attempt.
+	Result Result `json:"result"`
+
 	stdout io.WriteCloser
 	stderr io.WriteCloser
+
+	captureStore files.Store
+	captureDir   files.Dir
+	captureOut   io.WriteCloser
+	captureErr   io.WriteCloser
+}
+
+// Result records what happened when a task instance was run.
+type Result struct {
+	// ExitCode is the exit code of the task command, if the runner
+	// was able to determine it.
+	ExitCode int `json:"exit_code"`
+
+	// Started is the time the runner was asked to run the task.
+	Started time.Time `json:"started"`
+
+	// Finished is the time the runner finished running the task,
+	// whether it succeeded or not.
+	Finished time.Time `json:"finished"`
+
+	// Error is the error message, if the task failed.
+	Error string `json:"error,omitempty"`
 }
 
 func NewInstance(t spec.Task, opts ...option.Func[*Instance]) (*Instance, error) {
@@ -39,6 +83,8 @@ func NewInstance(t spec.Task, opts ...option.Func[*Instance]) (*Instance, error)
 	return instance, nil
 }
 
+// WithStdout adds a writer that will receive the standard output of
+// the task as it runs, in addition to any capture.
 func WithStdout(w io.WriteCloser) option.Func[*Instance] {
 	return func(instance *Instance) error {
 		instance.stdout = w
@@ -46,6 +92,8 @@ func WithStdout(w io.WriteCloser) option.Func[*Instance] {
 	}
 }
 
+// WithStderr adds a writer that will receive the standard error of
+// the task as it runs, in addition to any capture.
 func WithStderr(w io.WriteCloser) option.Func[*Instance] {
 	return func(instance *Instance) error {
 		instance.stderr = w
@@ -53,19 +101,110 @@ func WithStderr(w io.WriteCloser) option.Func[*Instance] {
 	}
 }
 
+// WithCapture causes the standard output and error of the task to
+// be saved to stdout.txt and stderr.txt in the given directory, using
+// the given store. When the instance is finalized, a metadata file
+// describing the result will be saved there as well. Files are only
+// created once the runner asks for the instance writers.
+func WithCapture(s files.Store, d files.Dir) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.captureStore = s
+		instance.captureDir = d
+		return nil
+	}
+}
+
+// Stdout returns the writer that the runner should stream the
+// standard output of the task into, as it is produced, or nil if
+// there isn't one.
 func (t *Instance) Stdout() io.Writer {
-	return t.stdout
+	if t.captureStore != nil && t.captureOut == nil {
+		t.captureOut = output.Stream(t.captureStore, t.captureDir.PathTo("stdout.txt"))
+	}
+
+	return combine(t.captureOut, t.stdout)
 }
 
+// Stderr returns the writer that the runner should stream the
+// standard error of the task into, as it is produced, or nil if
+// there isn't one.
 func (t *Instance) Stderr() io.Writer {
-	return t.stderr
+	if t.captureStore != nil && t.captureErr == nil {
+		t.captureErr = output.Stream(t.captureStore, t.captureDir.PathTo("stderr.txt"))
+	}
+
+	return combine(t.captureErr, t.stderr)
+}
+
+// CaptureDir returns the directory that task output is being
+// captured to, if any.
+func (t *Instance) CaptureDir() (files.Dir, bool) {
+	return t.captureDir, t.captureStore != nil
+}
+
+// Done indicates whether the task instance has been run and finished
+// successfully.
+func (t *Instance) Done() bool {
+	return !t.Result.Finished.IsZero() && t.Result.Error == ""
+}
+
+// reset prepares a task instance that has already been run (and
+// finalized) to be run again. Captured output from the previous
+// attempt will be replaced.
+func (t *Instance) reset() {
+	t.Result = Result{}
+	t.captureOut = nil
+	t.captureErr = nil
 }
 
 func (t *Instance) Finalize() error {
 	var errs []error
 
-	errs = append(errs, t.stdout.Close())
-	errs = append(errs, t.stderr.Close())
+	for _, w := range []io.WriteCloser{t.captureOut, t.captureErr, t.stdout, t.stderr} {
+		if w != nil {
+			errs = append(errs, w.Close())
+		}
+	}
+
+	if t.captureStore != nil {
+		errs = append(errs, t.saveMetadata())
+	}
 
 	return errors.Join(errs...)
 }
+
+func (t *Instance) saveMetadata() error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	p := t.captureDir.PathTo(MetadataFileName)
+
+	err = t.captureStore.Save(p, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to save metadata (%s): %w", p, err)
+	}
+
+	return nil
+}
+
+// combine returns a writer that writes to all of the given writers
+// that are not nil, or nil if they are all nil.
+func combine(ws ...io.WriteCloser) io.Writer {
+	var present []io.Writer
+	for _, w := range ws {
+		if w != nil {
+			present = append(present, w)
+		}
+	}
+
+	switch len(present) {
+	case 0:
+		return nil
+	case 1:
+		return present[0]
+	default:
+		return io.MultiWriter(present...)
+	}
+}
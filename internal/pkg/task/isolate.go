@@ -0,0 +1,141 @@
+package task
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// RunIsolated is like RunAll, but runs each task in its own volume,
+// which contains only the inputs the task declares. The first volume
+// must be provided, populated with the inputs of the first task, and
+// the rest are created using the runner. Inputs for later tasks are
+// linked (or copied) forward from the volumes of the earlier tasks
+// that produced them.
+//
+// Since the volume of a task is never modified by the tasks after it,
+// a failed task can be retried from its own inputs.
+//
+// The volumes used are returned, in task order, even if an error
+// occurs, so that the caller can delete them. There may be fewer
+// volumes than tasks if a task fails.
+//
+// To resume after a failure, call RunIsolated again with the volumes
+// it returned. Tasks that are already done (see Instance.Done) will be
+// skipped, and the volume of the task that failed will be replaced
+// with a fresh one.
+func RunIsolated(r Runner, tasks []*Instance, vols []Volume) ([]Volume, error) {
+	if len(vols) == 0 {
+		return vols, fmt.Errorf("a volume for the first task is required")
+	}
+
+	for i, inst := range tasks {
+		if inst.Done() {
+			continue
+		}
+
+		if i > 0 && i < len(vols) {
+			// The task failed previously, so we throw away whatever
+			// it left behind.
+			for _, v := range vols[i+1:] {
+				err := r.DeleteVolume(v)
+				if err != nil {
+					return vols, fmt.Errorf("failed to delete volume %s for retry: %w", v, err)
+				}
+			}
+
+			v, err := ClearOrReplace(r, vols[i])
+			if err != nil {
+				return vols[:i], fmt.Errorf("failed to reset volume for %s: %w", inst.Task.Name, err)
+			}
+			vols = append(vols[:i], v)
+		} else if i > 0 {
+			v, err := r.CreateVolume(0)
+			if err != nil {
+				return vols, fmt.Errorf("failed to create volume for %s: %w", inst.Task.Name, err)
+			}
+			vols = append(vols, v)
+		}
+
+		if i > 0 {
+			v := vols[i]
+
+			err := stageInputs(r, tasks[:i], vols[:i], inst, v)
+			if err != nil {
+				return vols, fmt.Errorf("failed to stage inputs for %s on %s: %w", inst.Task.Name, v, err)
+			}
+		}
+
+		slog.Info("running task instance", "name", inst.Task.Name, "id", inst.ID, "volume", vols[i])
+
+		err := run(r, inst, vols[i])
+		if err != nil {
+			return vols, fmt.Errorf("failed to run isolated task on %s: %w", vols[i], err)
+		}
+	}
+
+	return vols, nil
+}
+
+// stageInputs links each of the inputs of the given task instance
+// into its volume from the volume of the earlier task that provides
+// it. The earlier tasks and their volumes must be given in order.
+func stageInputs(r Runner, earlier []*Instance, vols []Volume, inst *Instance, v Volume) error {
+	for _, in := range inst.Inputs {
+		src := in.File()
+		index := -1
+
+		if in.From != nil {
+			src = in.From.Output.File()
+			index = lastIndex(earlier, func(e *Instance) bool {
+				return e.Name == in.From.Task
+			})
+		} else {
+			// The most recent task that mentions the file is assumed
+			// to have the latest version of it.
+			index = lastIndex(earlier, func(e *Instance) bool {
+				return mentions(e, src)
+			})
+		}
+
+		if index < 0 {
+			return fmt.Errorf("no earlier task provides input %s", in.Name)
+		}
+
+		slog.Debug("staging task input", "name", inst.Task.Name, "id", inst.ID, "from", earlier[index].Name, "src", src, "dest", in.File())
+
+		err := r.LinkFile(vols[index], src, v, in.File())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lastIndex(insts []*Instance, match func(*Instance) bool) int {
+	for i := len(insts) - 1; i >= 0; i-- {
+		if match(insts[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// mentions indicates whether the given file name is one of the inputs
+// or outputs of the given task instance.
+func mentions(inst *Instance, name string) bool {
+	for _, out := range inst.Outputs {
+		if out.File() == name {
+			return true
+		}
+	}
+
+	for _, in := range inst.Inputs {
+		if in.File() == name {
+			return true
+		}
+	}
+
+	return false
+}
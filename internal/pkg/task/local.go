@@ -0,0 +1,91 @@
+package task
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// LocalRunner runs each task as a process directly on the host,
+// without a container, using the volume as its working directory.
+// It is useful for developing and testing workflows on machines that
+// don't have Docker installed.
+//
+// Since there is no container, the task command must be available on
+// the host, and any paths it uses must be relative to the working
+// directory (the task WorkDir is not used).
+type LocalRunner struct {
+	// Debug indicates whether debug mode is enabled. In debug mode,
+	// the runner doesn't delete volumes and may provide additional
+	// output.
+	Debug bool
+
+	// IgnoreImage allows tasks that specify an image to be run
+	// anyway, outside of that image. Tasks with an image are
+	// refused unless this is set, since they may rely on software
+	// that only exists inside the image.
+	IgnoreImage bool
+
+	// WorkDir is the local (host) working directory, used to store
+	// data for tasks to operate on. Subdirectories will be created
+	// and used as the working directory for each task.
+	WorkDir files.Dir
+
+	// Store is the store to be used for reading and writing files
+	// to volumes.
+	Store files.Store
+}
+
+func (r *LocalRunner) CreateVolume(s files.Size) (Volume, error) {
+	return createLocalVolume(r.WorkDir)
+}
+
+func (r *LocalRunner) DeleteVolume(v Volume) error {
+	return deleteLocalVolume(v, r.Debug)
+}
+
+func (r *LocalRunner) AddFile(s files.Path, v Volume, name string) error {
+	return addLocalFile(r.Store, s, v, name)
+}
+
+func (r *LocalRunner) ExtractFile(s files.Path, v Volume, d files.Dir) error {
+	return extractLocalFile(r.Store, s, v, d)
+}
+
+func (r *LocalRunner) LinkFile(sv Volume, src string, dv Volume, dest string) error {
+	return linkLocalFile(sv, src, dv, dest)
+}
+
+func (r *LocalRunner) PruneVolume(v Volume, keep []string) error {
+	return pruneLocalVolume(v, keep, r.Debug)
+}
+
+// ClearVolume deletes the contents of the given volume.
+func (r *LocalRunner) ClearVolume(v Volume) error {
+	return pruneLocalVolume(v, nil, false)
+}
+
+func (r *LocalRunner) Env() files.Env {
+	return files.EnvLocal
+}
+
+func (r *LocalRunner) Run(inst *Instance, v Volume) error {
+	if len(inst.Cmd) == 0 {
+		return fmt.Errorf("task %s has no command", inst.Name)
+	}
+
+	if inst.Image != "" {
+		if !r.IgnoreImage {
+			return fmt.Errorf("task %s requires image %s, refusing to run it locally", inst.Name, inst.Image)
+		}
+		slog.Debug("ignoring task image", "name", inst.Name, "image", inst.Image)
+	}
+
+	_, err := streamCommand(inst, string(v), inst.Cmd)
+	if err != nil {
+		return fmt.Errorf("failed to run local command (%v): %w", inst.Cmd, err)
+	}
+
+	return nil
+}
@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
 
 	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/shell"
 )
 
 func outputWriters(outputDir files.Dir) (stdout io.WriteCloser, stderr io.WriteCloser, err error) {
@@ -27,17 +27,30 @@ func outputWriters(outputDir files.Dir) (stdout io.WriteCloser, stderr io.WriteC
 	return stdout, stderr, nil
 }
 
-func writeOutput(outputDir, name, data string) error {
-	outPath := path.Join(outputDir, name)
-	out, err := os.Create(outPath)
-	if err != nil {
-		return fmt.Errorf("failed to open output file (%s): %w", outPath, err)
-	}
+// streamCommand runs the given command in the given (host) directory,
+// streaming its output into the instance's writers, and records the
+// exit code on the instance. It returns the exit code of the command,
+// see shell.Stream.
+func streamCommand(inst *Instance, dir string, command []string) (int, error) {
+	stdout, stderr := instanceWriters(inst)
 
-	_, err = io.WriteString(out, data)
-	if err != nil {
-		return fmt.Errorf("failed to write output file (%s): %w", outPath, err)
+	code, err := shell.Stream(dir, command, stdout, stderr)
+	inst.Result.ExitCode = code
+
+	return code, err
+}
+
+// instanceWriters returns the writers that task output should be
+// streamed into, discarding output if the instance has nowhere to
+// put it.
+func instanceWriters(inst *Instance) (stdout io.Writer, stderr io.Writer) {
+	stdout, stderr = inst.Stdout(), inst.Stderr()
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
 	}
 
-	return nil
+	return stdout, stderr
 }
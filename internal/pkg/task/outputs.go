@@ -0,0 +1,41 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+// OutputsDirName is the name of the directory, alongside the outputs
+// extracted from a volume, that holds a record for each output, with
+// its size and checksums, so that they can be verified later. Records
+// are named after their outputs, with a .json extension.
+const OutputsDirName = "flowork-outputs"
+
+// OutputRecord describes an extracted output (see OutputsDirName).
+type OutputRecord struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	files.Checksums
+}
+
+// recordOutput saves the record for an output extracted to the given
+// directory, replacing any earlier record for it. Each output gets its
+// own record so that they can be written independently.
+func recordOutput(store files.Store, d files.Dir, record OutputRecord) error {
+	p := d.SubDir(OutputsDirName).PathTo(record.Name + ".json")
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output record: %w", err)
+	}
+
+	err = store.Save(p, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to save output record (%s): %w", p, err)
+	}
+
+	return nil
+}
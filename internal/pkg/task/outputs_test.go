@@ -0,0 +1,64 @@
+package task
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestLocalRunner_ExtractFile(t *testing.T) {
+	r := &LocalRunner{
+		WorkDir: files.Dir(t.TempDir()),
+		Store:   &files.Local{},
+	}
+
+	v, err := r.CreateVolume(0)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(string(v), "b.txt"), []byte("hello"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(string(v), "a.txt"), []byte(""), 0644))
+
+	out := files.Dir(t.TempDir()).SubDir("outputs")
+	for _, name := range []string{"b.txt", "a.txt", "b.txt"} {
+		assert.NoError(t, r.ExtractFile(files.Path(name), v, out))
+	}
+
+	var records []OutputRecord
+	for _, name := range []string{"a.txt", "b.txt"} {
+		data, err := os.ReadFile(string(out.SubDir(OutputsDirName).PathTo(name + ".json")))
+		assert.NoError(t, err)
+
+		var record OutputRecord
+		assert.NoError(t, json.Unmarshal(data, &record))
+		records = append(records, record)
+	}
+
+	entries, err := os.ReadDir(string(out.SubDir(OutputsDirName)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	assert.Equal(t, []OutputRecord{
+		{
+			Name: "a.txt",
+			Size: 0,
+			Checksums: files.Checksums{
+				SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				MD5:    "d41d8cd98f00b204e9800998ecf8427e",
+				CRC32C: "00000000",
+			},
+		},
+		{
+			Name: "b.txt",
+			Size: 5,
+			Checksums: files.Checksums{
+				SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+				MD5:    "5d41402abc4b2a76b9719d911017c592",
+				CRC32C: "9a71bb4c",
+			},
+		},
+	}, records)
+}
@@ -0,0 +1,55 @@
+package task
+
+import (
+	"sync"
+)
+
+// volumePool holds volumes that have been cleared and can be handed
+// out again instead of creating new ones. The zero value is an empty
+// pool, ready to use.
+type volumePool struct {
+	lock sync.Mutex
+	idle []Volume
+}
+
+// get removes a volume from the pool, if there are any.
+func (p *volumePool) get() (Volume, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.idle) == 0 {
+		return "", false
+	}
+
+	v := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+
+	return v, true
+}
+
+// put adds a volume to the pool, unless the pool already holds the
+// given maximum number of volumes. It returns true if the volume was
+// added.
+func (p *volumePool) put(v Volume, max int) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.idle) >= max {
+		return false
+	}
+
+	p.idle = append(p.idle, v)
+
+	return true
+}
+
+// drain empties the pool and returns the volumes it held.
+func (p *volumePool) drain() []Volume {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idle := p.idle
+	p.idle = nil
+
+	return idle
+}
@@ -0,0 +1,108 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+)
+
+func TestDockerRunner_pool(t *testing.T) {
+	t.Run("should reuse cleared volumes", func(t *testing.T) {
+		r := &DockerRunner{
+			WorkDir:  files.Dir(t.TempDir()),
+			PoolSize: 1,
+		}
+
+		v, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(string(v), "a.txt"), nil, 0644))
+		assert.NoError(t, os.Mkdir(filepath.Join(string(v), "sub"), 0755))
+
+		assert.NoError(t, r.DeleteVolume(v))
+
+		reused, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+		assert.Equal(t, v, reused)
+		assert.Equal(t, []string(nil), volumeFiles(t, reused))
+	})
+
+	t.Run("should delete volumes beyond the pool size", func(t *testing.T) {
+		r := &DockerRunner{
+			WorkDir:  files.Dir(t.TempDir()),
+			PoolSize: 1,
+		}
+
+		v0, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+		v1, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+
+		assert.NoError(t, r.DeleteVolume(v0))
+		assert.NoError(t, r.DeleteVolume(v1))
+
+		_, err = os.Stat(string(v0))
+		assert.NoError(t, err)
+		_, err = os.Stat(string(v1))
+		assert.True(t, os.IsNotExist(err))
+
+		assert.NoError(t, r.Close())
+		_, err = os.Stat(string(v0))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("should not pool volumes when disabled", func(t *testing.T) {
+		r := &DockerRunner{
+			WorkDir: files.Dir(t.TempDir()),
+		}
+
+		v, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+		assert.NoError(t, r.DeleteVolume(v))
+
+		_, err = os.Stat(string(v))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+// replacer is a Runner that can't clear volumes.
+type replacer struct {
+	*LocalRunner
+}
+
+func (r replacer) ClearVolume(v Volume) error {
+	return errors.ErrUnsupported
+}
+
+func TestClearOrReplace(t *testing.T) {
+	local := &LocalRunner{
+		WorkDir: files.Dir(t.TempDir()),
+	}
+
+	t.Run("should clear the volume when possible", func(t *testing.T) {
+		v, err := local.CreateVolume(0)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(string(v), "a.txt"), nil, 0644))
+
+		cleared, err := ClearOrReplace(local, v)
+		assert.NoError(t, err)
+		assert.Equal(t, v, cleared)
+		assert.Equal(t, []string(nil), volumeFiles(t, cleared))
+	})
+
+	t.Run("should replace the volume when clearing is unsupported", func(t *testing.T) {
+		v, err := local.CreateVolume(0)
+		assert.NoError(t, err)
+
+		replaced, err := ClearOrReplace(replacer{local}, v)
+		assert.NoError(t, err)
+		assert.NotEqual(t, v, replaced)
+
+		_, err = os.Stat(string(v))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
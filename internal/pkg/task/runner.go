@@ -1,6 +1,9 @@
 package task
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/glesica/flowork/internal/pkg/files"
 )
 
@@ -39,7 +42,74 @@ type Runner interface {
 	// the name will be taken from the source path.
 	ExtractFile(s files.Path, v Volume, d files.Dir) error
 
+	// LinkFile makes the file with the given source name, in the root
+	// of the source volume, available under the destination name in
+	// the root of the destination volume (which may be the same), by
+	// whatever means makes the most sense (a link or a copy). It is
+	// used to wire the outputs of one task to the inputs of another.
+	LinkFile(sv Volume, src string, dv Volume, dest string) error
+
+	// PruneVolume deletes everything in the root of the given volume
+	// except for the files with the given names. It is used to clean
+	// up intermediate files that later tasks don't need. Runners may
+	// choose to ignore this (in a debug mode, for example), or return
+	// errors.ErrUnsupported if they can't do it at all.
+	PruneVolume(v Volume, keep []string) error
+
 	// Run executes the given task using the given data volume as the
 	// task working directory.
 	Run(t *Instance, v Volume) error
 }
+
+// A Clearer is a Runner that can delete the contents of a volume so
+// that it can be reused, which may be much faster than deleting it and
+// creating a new one.
+type Clearer interface {
+	// ClearVolume deletes everything in the given volume, but leaves
+	// the volume itself intact.
+	ClearVolume(v Volume) error
+}
+
+// ClearOrReplace empties the given volume so that it can be used
+// again. If the runner can't clear volumes, either because it isn't a
+// Clearer or because ClearVolume returned errors.ErrUnsupported, the
+// volume is deleted and a new one is created in its place.
+func ClearOrReplace(r Runner, v Volume) (Volume, error) {
+	if c, ok := r.(Clearer); ok {
+		err := c.ClearVolume(v)
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return "", fmt.Errorf("failed to clear volume %s: %w", v, err)
+		}
+	}
+
+	err := r.DeleteVolume(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to delete volume %s: %w", v, err)
+	}
+
+	return r.CreateVolume(0)
+}
+
+// EnvSsh is the environment of a remote machine accessed over SSH.
+const EnvSsh files.Env = "ssh"
+
+// A Locator is a Runner that can report the environment in which
+// its volumes exist, and therefore where its tasks read and write
+// their data.
+type Locator interface {
+	Env() files.Env
+}
+
+// EnvOf returns the environment of the given runner if it supports
+// reporting it, and files.EnvUnknown otherwise.
+func EnvOf(r Runner) files.Env {
+	locator, ok := r.(Locator)
+	if !ok {
+		return files.EnvUnknown
+	}
+
+	return locator.Env()
+}
@@ -1,6 +1,7 @@
 package task
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -151,8 +152,18 @@ func (r *SshRunner) ExtractFile(s files.Path, v Volume, d files.Dir) error {
 	panic("implement me")
 }
 
+func (r *SshRunner) LinkFile(sv Volume, src string, dv Volume, dest string) error {
+	// TODO: Link or copy on the remote machine
+	return fmt.Errorf("SshRunner.LinkFile: %w", errors.ErrUnsupported)
+}
+
+func (r *SshRunner) PruneVolume(v Volume, keep []string) error {
+	// TODO: Delete files on the remote machine
+	return fmt.Errorf("SshRunner.PruneVolume: %w", errors.ErrUnsupported)
+}
+
 func (r *SshRunner) Run(t *Instance, v Volume) error {
-	command, err := DockerRun(t, v, r.user)
+	command, err := Docker{}.RunCommand(t, v, r.user)
 	if err != nil {
 		return fmt.Errorf("SshRunner.Run: failed to build docker command: %w", err)
 	}
@@ -160,6 +171,10 @@ func (r *SshRunner) Run(t *Instance, v Volume) error {
 	return r.execute("Run", command, true)
 }
 
+func (r *SshRunner) Env() files.Env {
+	return EnvSsh
+}
+
 func (r *SshRunner) Close() error {
 	return r.client.Close()
 }
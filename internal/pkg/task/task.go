@@ -1,23 +1,126 @@
 package task
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 )
 
 // RunAll applies the given tasks, using the given runner, to the given
-// volume, in the order that they are provided.
-func RunAll(r Runner, tasks []*Instance, v Volume) error {
-	for _, inst := range tasks {
+// volume, in the order that they are provided. Unless keepIntermediates
+// is set, after each task, the volume is pruned down to the files
+// needed by the tasks that remain, and the outputs of the last task.
+// Runners that can't prune volumes (see Runner.PruneVolume) keep them.
+//
+// Tasks that are already done (see Instance.Done) are skipped, so
+// RunAll can be called again, with the same volume, to resume after
+// a failure. The failed task will run against the volume as it left
+// it, see RunIsolated for a way to avoid this.
+func RunAll(r Runner, tasks []*Instance, v Volume, keepIntermediates bool) error {
+	for i, inst := range tasks {
+		if inst.Done() {
+			continue
+		}
+
 		slog.Info("running task instance", "name", inst.Task.Name, "id", inst.ID, "volume", v)
 
-		// TODO: We could copy output names to input names to make tasks easier to re-use
+		err := linkInputs(r, inst, v)
+		if err != nil {
+			return fmt.Errorf("failed to wire inputs for %s on %s: %w", inst.Task.Name, v, err)
+		}
 
-		err := r.Run(inst, v)
+		err = run(r, inst, v)
 		if err != nil {
-			slog.Error("task instance failed", "name", inst.Task.Name, "id", inst.ID, "volume", v)
 			return fmt.Errorf("failed to run all tasks on %s: %w", v, err)
 		}
+
+		if keepIntermediates {
+			continue
+		}
+
+		keep := needed(tasks[i+1:], tasks[len(tasks)-1])
+		err = r.PruneVolume(v, keep)
+		if errors.Is(err, errors.ErrUnsupported) {
+			slog.Debug("runner can't prune volumes, keeping intermediate files", "volume", v)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to prune %s after %s: %w", v, inst.Task.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// needed returns the names of the files that the given remaining
+// tasks reference as inputs, including outputs wired to inputs, along
+// with the outputs of the final task, which will be extracted.
+func needed(remaining []*Instance, last *Instance) []string {
+	var names []string
+	for _, inst := range remaining {
+		for _, in := range inst.Inputs {
+			names = append(names, in.File())
+			if in.From != nil {
+				names = append(names, in.From.Output.File())
+			}
+		}
+	}
+
+	for _, out := range last.Outputs {
+		names = append(names, out.File())
+	}
+
+	return names
+}
+
+// linkInputs makes outputs of earlier tasks available under the
+// names of the inputs they are wired to (see spec.Input).
+func linkInputs(r Runner, inst *Instance, v Volume) error {
+	for _, in := range inst.Inputs {
+		if in.From == nil {
+			continue
+		}
+
+		src, dest := in.From.Output.File(), in.File()
+		if src == dest {
+			continue
+		}
+
+		slog.Debug("wiring task input", "name", inst.Task.Name, "id", inst.ID, "from", in.From.Task, "src", src, "dest", dest)
+
+		err := r.LinkFile(v, src, v, dest)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run runs a single task instance, recording its timing and result,
+// and finalizes it.
+func run(r Runner, inst *Instance, v Volume) error {
+	if inst.Attempts > 0 {
+		inst.reset()
+	}
+
+	inst.Attempts++
+	inst.Result.Started = time.Now()
+	err := r.Run(inst, v)
+	inst.Result.Finished = time.Now()
+	if err != nil {
+		inst.Result.Error = err.Error()
+	}
+
+	finalizeErr := inst.Finalize()
+	if finalizeErr != nil {
+		slog.Warn("failed to finalize task instance", "name", inst.Task.Name, "id", inst.ID, "error", finalizeErr)
+	}
+
+	if err != nil {
+		slog.Error("task instance failed", "name", inst.Task.Name, "id", inst.ID, "volume", v)
+		return err
 	}
 
 	return nil
@@ -0,0 +1,160 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/spec"
+)
+
+// volumeFiles returns the sorted names of the files in the root of
+// the given volume.
+func volumeFiles(t *testing.T, v Volume) []string {
+	entries, err := os.ReadDir(string(v))
+	assert.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// unprunableRunner is a LocalRunner that can't prune volumes.
+type unprunableRunner struct {
+	*LocalRunner
+}
+
+func (r *unprunableRunner) PruneVolume(v Volume, keep []string) error {
+	return errors.ErrUnsupported
+}
+
+func TestRunAll(t *testing.T) {
+	specs := []spec.Task{
+		{
+			Name:    "split",
+			Cmd:     []string{"sh", "-c", "echo a > a.txt && echo b > scratch.txt"},
+			Outputs: []files.Path{"a.txt"},
+		},
+		{
+			Name:    "copy",
+			Cmd:     []string{"cp", "in.txt", "out.txt"},
+			Inputs:  []spec.Input{{Name: "in.txt", From: &spec.Source{Task: "split", Output: "a.txt"}}},
+			Outputs: []files.Path{"out.txt"},
+		},
+	}
+
+	setup := func(t *testing.T, debug bool) (*LocalRunner, Volume, []*Instance) {
+		r := &LocalRunner{
+			Debug:   debug,
+			WorkDir: files.Dir(t.TempDir()),
+			Store:   &files.Local{},
+		}
+
+		v, err := r.CreateVolume(0)
+		assert.NoError(t, err)
+
+		var insts []*Instance
+		for _, s := range specs {
+			inst, err := NewInstance(s)
+			assert.NoError(t, err)
+			insts = append(insts, inst)
+		}
+
+		return r, v, insts
+	}
+
+	t.Run("should prune intermediate files", func(t *testing.T) {
+		r, v, insts := setup(t, false)
+
+		err := RunAll(r, insts, v, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"out.txt"}, volumeFiles(t, v))
+	})
+
+	t.Run("should keep intermediate files when asked", func(t *testing.T) {
+		r, v, insts := setup(t, false)
+
+		err := RunAll(r, insts, v, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a.txt", "in.txt", "out.txt", "scratch.txt"}, volumeFiles(t, v))
+	})
+
+	t.Run("should keep intermediate files if the runner can't prune", func(t *testing.T) {
+		r, v, insts := setup(t, false)
+
+		err := RunAll(&unprunableRunner{r}, insts, v, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a.txt", "in.txt", "out.txt", "scratch.txt"}, volumeFiles(t, v))
+	})
+
+	t.Run("should keep intermediate files in debug mode", func(t *testing.T) {
+		r, v, insts := setup(t, true)
+
+		err := RunAll(r, insts, v, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a.txt", "in.txt", "out.txt", "scratch.txt"}, volumeFiles(t, v))
+
+		content, err := os.ReadFile(filepath.Join(string(v), "out.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "a\n", string(content))
+	})
+}
+
+func TestRunIsolated(t *testing.T) {
+	r := &LocalRunner{
+		WorkDir: files.Dir(t.TempDir()),
+		Store:   &files.Local{},
+	}
+
+	var insts []*Instance
+	for _, s := range []spec.Task{
+		{
+			Name:    "split",
+			Cmd:     []string{"sh", "-c", "echo a > a.txt && echo b > scratch.txt"},
+			Inputs:  []spec.Input{{Name: "raw.txt"}},
+			Outputs: []files.Path{"a.txt"},
+		},
+		{
+			Name:    "copy",
+			Cmd:     []string{"cp", "in.txt", "out.txt"},
+			Inputs:  []spec.Input{{Name: "in.txt", From: &spec.Source{Task: "split", Output: "a.txt"}}},
+			Outputs: []files.Path{"out.txt"},
+		},
+		{
+			Name:    "join",
+			Cmd:     []string{"sh", "-c", "cat raw.txt out.txt > joined.txt"},
+			Inputs:  []spec.Input{{Name: "raw.txt"}, {Name: "out.txt"}},
+			Outputs: []files.Path{"joined.txt"},
+		},
+	} {
+		inst, err := NewInstance(s)
+		assert.NoError(t, err)
+		insts = append(insts, inst)
+	}
+
+	first, err := r.CreateVolume(0)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(string(first), "raw.txt"), []byte("raw\n"), 0644)
+	assert.NoError(t, err)
+
+	vols, err := RunIsolated(r, insts, []Volume{first})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(vols))
+
+	assert.Equal(t, []string{"a.txt", "raw.txt", "scratch.txt"}, volumeFiles(t, vols[0]))
+	assert.Equal(t, []string{"in.txt", "out.txt"}, volumeFiles(t, vols[1]))
+	assert.Equal(t, []string{"joined.txt", "out.txt", "raw.txt"}, volumeFiles(t, vols[2]))
+
+	content, err := os.ReadFile(filepath.Join(string(vols[2]), "joined.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "raw\na\n", string(content))
+}
@@ -0,0 +1,151 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/glesica/flowork/internal/app/options"
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/id"
+)
+
+// The helpers in this file implement volumes as directories on the
+// local (host) file system, for use by runners that execute tasks on
+// the orchestrator itself.
+
+func createLocalVolume(workDir files.Dir) (Volume, error) {
+	volDir := filepath.Join(string(workDir), options.VolumesDirName, id.New())
+
+	err := os.MkdirAll(volDir, 0777)
+	if err != nil {
+		return "", fmt.Errorf("failed to create volume (%s): %w", volDir, err)
+	}
+
+	return Volume(volDir), nil
+}
+
+func deleteLocalVolume(v Volume, debug bool) error {
+	if debug {
+		slog.Debug("delete volume requested, ignoring", "volume", v)
+		return nil
+	}
+
+	err := os.RemoveAll(string(v))
+	if err != nil {
+		return fmt.Errorf("failed to delete volume %s: %w", v, err)
+	}
+
+	return nil
+}
+
+func pruneLocalVolume(v Volume, keep []string, debug bool) error {
+	if debug {
+		slog.Debug("prune volume requested, ignoring", "volume", v)
+		return nil
+	}
+
+	entries, err := os.ReadDir(string(v))
+	if err != nil {
+		return fmt.Errorf("failed to list volume %s: %w", v, err)
+	}
+
+	keepSet := map[string]bool{}
+	for _, name := range keep {
+		keepSet[name] = true
+	}
+
+	for _, e := range entries {
+		if keepSet[e.Name()] {
+			continue
+		}
+
+		p := filepath.Join(string(v), e.Name())
+		err := os.RemoveAll(p)
+		if err != nil {
+			return fmt.Errorf("failed to prune %s: %w", p, err)
+		}
+
+		slog.Debug("pruned volume file", "volume", v, "name", e.Name())
+	}
+
+	return nil
+}
+
+// TODO: Make name a path and create intermediate directories
+
+func addLocalFile(store files.Store, s files.Path, v Volume, name string) error {
+	dest := filepath.Join(string(v), name)
+
+	_, _, err := files.Copy(store, files.Path(dest), store, s)
+	if err != nil {
+		return fmt.Errorf("failed to add file %s as %s: %w", s, dest, err)
+	}
+
+	return nil
+}
+
+// extractLocalFile copies the file out of the volume, and records its
+// size and checksums next to it (see OutputsDirName).
+func extractLocalFile(store files.Store, s files.Path, v Volume, d files.Dir) error {
+	name := s.File()
+	src := filepath.Join(string(v), name)
+	dest := d.PathTo(name)
+
+	size, sums, err := files.Copy(store, dest, store, files.Path(src))
+	if err != nil {
+		return fmt.Errorf("failed to extract file %s to %s: %w", s, d, err)
+	}
+
+	return recordOutput(store, d, OutputRecord{
+		Name:      name,
+		Size:      int64(size),
+		Checksums: sums,
+	})
+}
+
+// linkLocalFile hard links the source file into place, falling back
+// to a copy if that isn't possible (the volumes are on different file
+// systems, for example). Since a hard link shares its content with the
+// original, tasks should replace their inputs rather than modifying
+// them in place.
+func linkLocalFile(sv Volume, src string, dv Volume, dest string) error {
+	srcPath := filepath.Join(string(sv), src)
+	destPath := filepath.Join(string(dv), dest)
+
+	// Replace anything already using the destination name, since the
+	// wiring is explicit.
+	err := os.Remove(destPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to replace %s: %w", destPath, err)
+	}
+
+	err = os.Link(srcPath, destPath)
+	if err == nil {
+		return nil
+	}
+
+	slog.Debug("failed to link file, copying instead", "src", srcPath, "dest", destPath, "error", err)
+
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for link: %w", srcPath, err)
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s for link: %w", destPath, err)
+	}
+	defer func() { _ = out.Close() }()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", srcPath, destPath, err)
+	}
+
+	return out.Close()
+}
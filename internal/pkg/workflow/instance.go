@@ -2,13 +2,17 @@ package workflow
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
 	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/files/output"
 	"github.com/glesica/flowork/internal/pkg/id"
 	"github.com/glesica/flowork/internal/pkg/option"
+	"github.com/glesica/flowork/internal/pkg/orchestrator"
 	"github.com/glesica/flowork/internal/pkg/spec"
+	"github.com/glesica/flowork/internal/pkg/task"
 )
 
 type Instance struct {
@@ -22,8 +26,37 @@ type Instance struct {
 	// CaptureDir is the directory where data from this workflow run
 	// will be written, such as logs, stdout, stderr, and so on.
 	// The default value is the current working directory with the
-	// instance ID appended to it.
+	// instance ID appended to it. It may be in any environment
+	// supported by the capture store (see WithCaptureStore).
 	CaptureDir files.Dir
+
+	// params holds the values of the workflow parameters, available
+	// to task templates (see spec.TemplateData).
+	params map[string]any
+
+	// runName is a human-readable name for the run, available to task
+	// templates as {{.Run.Name}}. The default is the instance ID.
+	runName string
+
+	// meta, if set, looks up the metadata that came with each input,
+	// available to task templates as {{.Input.Meta}}.
+	meta func(p files.Path) map[string]string
+
+	// onSuccess, if set, is called with the input of each job that
+	// succeeds.
+	onSuccess func(p files.Path) error
+
+	// maxRetries is the number of times a failed job will be retried,
+	// unless its failed task says otherwise.
+	maxRetries int
+
+	// captureStore is used to write captured data to CaptureDir.
+	captureStore files.Store
+
+	// echoOut and echoErr, if set, receive the output of every task
+	// as it runs, each line prefixed with the task name and job ID.
+	echoOut io.Writer
+	echoErr io.Writer
 }
 
 func NewInstance(w spec.Workflow, opts ...option.Func[*Instance]) (*Instance, error) {
@@ -50,6 +83,21 @@ func NewInstance(w spec.Workflow, opts ...option.Func[*Instance]) (*Instance, er
 		}
 	}
 
+	if instance.captureStore == nil {
+		instance.captureStore = &files.Local{}
+	}
+
+	if instance.params == nil {
+		instance.params, err = w.ParamValues(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set params: %w", err)
+		}
+	}
+
+	if instance.runName == "" {
+		instance.runName = instance.ID
+	}
+
 	slog.Info("created new workflow instance", "instance", instance)
 
 	return instance, nil
@@ -61,3 +109,142 @@ func WithWorkDir(d files.Dir) option.Func[*Instance] {
 		return nil
 	}
 }
+
+// WithCaptureDir sets the directory where captured data will be
+// written, see CaptureDir. Unlike WithWorkDir, the instance ID is not
+// appended to the given directory.
+func WithCaptureDir(d files.Dir) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.CaptureDir = d
+		return nil
+	}
+}
+
+// WithCaptureStore sets the store used to write captured data. The
+// default is the local file system.
+func WithCaptureStore(s files.Store) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.captureStore = s
+		return nil
+	}
+}
+
+// WithParams sets the values of the workflow parameters, see
+// spec.Workflow.ParamValues. By default, every parameter takes its
+// default value.
+func WithParams(values map[string]any) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.params = values
+		return nil
+	}
+}
+
+// WithRunName sets a human-readable name for the run, which tasks can
+// use in templates as {{.Run.Name}}.
+func WithRunName(name string) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.runName = name
+		return nil
+	}
+}
+
+// WithInputMeta sets a function that returns the metadata that came
+// with an input, such as the other columns of a manifest (see
+// inputs.Manifest.Meta). Tasks can use it in templates as
+// {{.Input.Meta.<name>}}.
+func WithInputMeta(lookup func(p files.Path) map[string]string) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.meta = lookup
+		return nil
+	}
+}
+
+// WithOnSuccess sets a function that is called with the input of each
+// job that succeeds, once its outputs have been extracted, such as
+// inputs.Watcher.Done. It may be called from several goroutines at
+// once. If it returns an error, the run fails.
+func WithOnSuccess(f func(p files.Path) error) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.onSuccess = f
+		return nil
+	}
+}
+
+// WithMaxRetries sets the number of times a failed job will be
+// retried, resuming at the task that failed, before it is abandoned.
+// Tasks may override this with their own Retries setting. The default
+// is zero.
+func WithMaxRetries(value int) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		if value < 0 {
+			return fmt.Errorf("max retries value must be non-negative: %d", value)
+		}
+		instance.maxRetries = value
+		return nil
+	}
+}
+
+// WithEcho causes the output of every task to be copied to the given
+// writers (generally the terminal) as it is produced. Each line will
+// be prefixed with "[task/job]" to identify its source.
+func WithEcho(stdout, stderr io.Writer) option.Func[*Instance] {
+	return func(instance *Instance) error {
+		instance.echoOut = stdout
+		instance.echoErr = stderr
+		return nil
+	}
+}
+
+// attach sets up output capture, and echo, if requested, for the
+// task instances that make up the given job. Output for each task
+// instance is captured to its own directory:
+// CaptureDir/<job-id>/<task-name>-<task-instance-id>/.
+func (w *Instance) attach(job *orchestrator.Job) error {
+	for _, inst := range job.Tasks {
+		dest := w.CaptureDir.SubDir(job.Id).SubDir(inst.Name + "-" + inst.ID)
+		opts := []option.Func[*task.Instance]{
+			task.WithCapture(w.captureStore, dest),
+		}
+
+		prefix := fmt.Sprintf("[%s/%s] ", inst.Name, job.Id)
+		if w.echoOut != nil {
+			opts = append(opts, task.WithStdout(output.Prefix(w.echoOut, prefix)))
+		}
+		if w.echoErr != nil {
+			opts = append(opts, task.WithStderr(output.Prefix(w.echoErr, prefix)))
+		}
+
+		err := option.Apply(inst, opts...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// render fills in the templates in the tasks that make up the given
+// job (see spec.TemplateData).
+func (w *Instance) render(job *orchestrator.Job) error {
+	var meta map[string]string
+	if w.meta != nil {
+		meta = w.meta(job.InPath)
+	}
+
+	data := spec.TemplateData{
+		Params: w.params,
+		Input:  spec.NewTemplateInput(job.InPath, meta),
+		Job:    spec.TemplateJob{ID: job.Id},
+		Run:    spec.TemplateRun{ID: w.ID, Name: w.runName},
+	}
+
+	for _, inst := range job.Tasks {
+		t, err := inst.Task.Render(data)
+		if err != nil {
+			return fmt.Errorf("task %s: %w", inst.Name, err)
+		}
+		inst.Task = t
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/inputs"
+	"github.com/glesica/flowork/internal/pkg/orchestrator/executor"
+	"github.com/glesica/flowork/internal/pkg/task"
+)
+
+// Run executes the given workflow instance once for each input
+// provided by the iterator, using the given runner. At most
+// concurrency jobs will be run at the same time. Outputs are
+// extracted into the given directory. Cancelling the context stops
+// new jobs from being started, jobs that are already running are
+// allowed to finish. Since the caller asked for it, cancellation
+// isn't reported as an error.
+//
+// A job that fails is retried, resuming at the task that failed, up
+// to the limit set with WithMaxRetries (or by the task itself). An
+// error is returned if any job fails for good, but a failed job does
+// not prevent the remaining jobs from running.
+func Run(ctx context.Context, wi *Instance, r task.Runner, in inputs.Iterator, out files.Dir, concurrency int64) error {
+	if len(wi.Tasks) == 0 {
+		return fmt.Errorf("workflow %s has no tasks", wi.ID)
+	}
+
+	stream, err := in(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to iterate over inputs: %w", err)
+	}
+
+	createJob := executor.MakeJobCreator(wi.Tasks)
+	sem := semaphore.NewWeighted(concurrency)
+	wg := sync.WaitGroup{}
+
+	lock := sync.Mutex{}
+	var errs []error
+	fail := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		errs = append(errs, err)
+	}
+
+	for inPath := range stream.Paths() {
+		job, err := createJob(inPath)
+		if err != nil {
+			fail(fmt.Errorf("failed to create job for %s: %w", inPath, err))
+			break
+		}
+		job.Runner = r
+		job.OutDir = out
+		job.KeepIntermediates = wi.KeepIntermediates
+		job.IsolateTasks = wi.IsolateTasks
+
+		err = wi.render(job)
+		if err != nil {
+			fail(fmt.Errorf("failed to render templates for job %s (%s): %w", job.Id, inPath, err))
+			break
+		}
+
+		err = wi.attach(job)
+		if err != nil {
+			fail(fmt.Errorf("failed to attach output to job %s: %w", job.Id, err))
+			break
+		}
+
+		err = sem.Acquire(ctx, 1)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			fail(fmt.Errorf("failed to acquire job slot: %w", err))
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			for {
+				job.Attempts++
+				err := executor.SimpleEngine(job)
+				if err == nil {
+					break
+				}
+				job.Err = err
+
+				if !job.Retryable(wi.maxRetries) {
+					slog.Error("job failed", "job", job.Id, "inpath", job.InPath, "error", err)
+					job.Release()
+					fail(fmt.Errorf("job %s (%s) failed: %w", job.Id, job.InPath, err))
+					return
+				}
+
+				slog.Warn("retrying job", "job", job.Id, "inpath", job.InPath, "completed", job.Completed(), "attempts", job.Attempts, "error", err)
+			}
+
+			slog.Info("job succeeded", "job", job.Id, "inpath", job.InPath)
+
+			if wi.onSuccess != nil {
+				err := wi.onSuccess(job.InPath)
+				if err != nil {
+					fail(fmt.Errorf("failed to record success of job %s (%s): %w", job.Id, job.InPath, err))
+				}
+			}
+		}()
+	}
+
+	// Stop the iteration, in case the loop was cut short, so that the
+	// reason it ended can be checked.
+	stream.Cancel()
+	err = stream.Err()
+	if err != nil && ctx.Err() == nil {
+		fail(fmt.Errorf("failed to iterate over inputs: %w", err))
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
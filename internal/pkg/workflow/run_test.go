@@ -0,0 +1,306 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/glesica/flowork/internal/pkg/files"
+	"github.com/glesica/flowork/internal/pkg/inputs"
+	"github.com/glesica/flowork/internal/pkg/option"
+	"github.com/glesica/flowork/internal/pkg/spec"
+	"github.com/glesica/flowork/internal/pkg/task"
+)
+
+// runFixture runs the named workflow fixture over the fixture inputs
+// using a local runner and returns the output directory, along with
+// the result of the run. Any options are applied to the instance.
+func runFixture(t *testing.T, name string, opts ...option.Func[*Instance]) (*Instance, files.Dir, error) {
+	ws, err := spec.LoadWorkflowPath(filepath.Join("fixtures", name))
+	assert.NoError(t, err)
+
+	workDir := files.Dir(t.TempDir())
+	outDir := workDir.SubDir("outputs")
+
+	wi, err := NewInstance(ws, append([]option.Func[*Instance]{WithWorkDir(workDir)}, opts...)...)
+	assert.NoError(t, err)
+
+	runner := &task.LocalRunner{
+		IgnoreImage: true,
+		WorkDir:     workDir,
+		Store:       &files.Local{},
+	}
+
+	inDir, err := filepath.Abs(filepath.Join("fixtures", "inputs"))
+	assert.NoError(t, err)
+
+	in, err := inputs.Local(files.Dir(inDir))
+	assert.NoError(t, err)
+
+	return wi, outDir, Run(context.Background(), wi, runner, in, outDir, 2)
+}
+
+func TestRun(t *testing.T) {
+	t.Run("should extract outputs of a successful workflow", func(t *testing.T) {
+		_, outDir, err := runFixture(t, "workflow_success.json")
+		assert.NoError(t, err)
+
+		outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "step1.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(outputs))
+
+		records, err := filepath.Glob(filepath.Join(string(outDir), "*", task.OutputsDirName, "step1.txt.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(records))
+	})
+
+	t.Run("should wire outputs to renamed inputs", func(t *testing.T) {
+		_, outDir, err := runFixture(t, "workflow_wired.json")
+		assert.NoError(t, err)
+
+		outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "step1.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(outputs))
+	})
+
+	t.Run("should render task templates", func(t *testing.T) {
+		_, outDir, err := runFixture(t, "workflow_params.json")
+		assert.NoError(t, err)
+
+		outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "file*-out.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(outputs))
+	})
+
+	t.Run("should render templates with given params", func(t *testing.T) {
+		_, outDir, err := runFixture(t, "workflow_params.json", WithParams(map[string]any{"suffix": "given"}))
+		assert.NoError(t, err)
+
+		outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "file0-given.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(outputs))
+	})
+
+	t.Run("should render templates with input metadata", func(t *testing.T) {
+		_, outDir, err := runFixture(t, "workflow_meta.json", WithInputMeta(func(p files.Path) map[string]string {
+			return map[string]string{"sample": "sample-" + strings.TrimSuffix(p.File(), ".txt")}
+		}))
+		assert.NoError(t, err)
+
+		outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "sample-file*.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(outputs))
+	})
+
+	t.Run("should process watched inputs until cancelled", func(t *testing.T) {
+		ws, err := spec.LoadWorkflowPath(filepath.Join("fixtures", "workflow_success.json"))
+		assert.NoError(t, err)
+
+		workDir := files.Dir(t.TempDir())
+		outDir := workDir.SubDir("outputs")
+
+		wi, err := NewInstance(ws, WithWorkDir(workDir))
+		assert.NoError(t, err)
+
+		runner := &task.LocalRunner{
+			IgnoreImage: true,
+			WorkDir:     workDir,
+			Store:       &files.Local{},
+		}
+
+		inDir, err := filepath.Abs(filepath.Join("fixtures", "inputs"))
+		assert.NoError(t, err)
+
+		w, err := inputs.NewWatcher(&files.Local{}, files.Dir(inDir), inputs.WithPollInterval(10*time.Millisecond))
+		assert.NoError(t, err)
+		in := w.Iterate
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- Run(ctx, wi, runner, in, outDir, 2)
+		}()
+
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "step1.txt"))
+			assert.NoError(t, err)
+			if len(outputs) == 4 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("only %d of 4 inputs were processed", len(outputs))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("should only remember watched inputs whose jobs succeed", func(t *testing.T) {
+		inDir, err := filepath.Abs(filepath.Join("fixtures", "inputs"))
+		assert.NoError(t, err)
+		state := filepath.Join(t.TempDir(), "state.txt")
+
+		watchFixture := func(name string) {
+			ws, err := spec.LoadWorkflowPath(filepath.Join("fixtures", name))
+			assert.NoError(t, err)
+
+			w, err := inputs.NewWatcher(&files.Local{}, files.Dir(inDir),
+				inputs.WithPollInterval(10*time.Millisecond),
+				inputs.WithStateFile(state),
+			)
+			assert.NoError(t, err)
+
+			var lock sync.Mutex
+			var succeeded []string
+			wi, err := NewInstance(ws, WithWorkDir(files.Dir(t.TempDir())), WithOnSuccess(func(p files.Path) error {
+				lock.Lock()
+				succeeded = append(succeeded, p.File())
+				lock.Unlock()
+				return w.Done(p)
+			}))
+			assert.NoError(t, err)
+
+			runner := &task.LocalRunner{
+				IgnoreImage: true,
+				WorkDir:     files.Dir(t.TempDir()),
+				Store:       &files.Local{},
+			}
+
+			// Give the watcher time to provide every input, then stop.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = Run(ctx, wi, runner, w.Iterate, files.Dir(t.TempDir()), 2)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if name == "workflow_success.json" {
+				assert.Equal(t, 4, len(succeeded))
+			} else {
+				assert.Equal(t, 0, len(succeeded))
+			}
+		}
+
+		// The failed inputs aren't recorded, so they are provided, and
+		// processed, again after a restart.
+		watchFixture("workflow_failure_task.json")
+		watchFixture("workflow_success.json")
+
+		data, err := os.ReadFile(state)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(strings.Fields(string(data))))
+	})
+
+	t.Run("should fail when a task fails", func(t *testing.T) {
+		_, outDir, err := runFixture(t, "workflow_failure_task.json")
+		assert.Error(t, err)
+
+		_, err = os.Stat(string(outDir))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("should capture task output and metadata", func(t *testing.T) {
+		wi, _, err := runFixture(t, "workflow_failure_task.json")
+		assert.Error(t, err)
+
+		// One directory per job, each with one per task instance.
+		pattern := filepath.Join(string(wi.CaptureDir), "*", "step1-*", "*")
+		captured, err := filepath.Glob(pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, 4*3, len(captured))
+
+		stderrPath := filepath.Join(filepath.Dir(captured[0]), "stderr.txt")
+		stderr, err := os.ReadFile(stderrPath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(stderr), "wrong_file.txt")
+
+		metaPath := filepath.Join(filepath.Dir(captured[0]), task.MetadataFileName)
+		metaData, err := os.ReadFile(metaPath)
+		assert.NoError(t, err)
+
+		meta := task.Instance{}
+		err = json.Unmarshal(metaData, &meta)
+		assert.NoError(t, err)
+		assert.Equal(t, "step1", meta.Name)
+		assert.NotZero(t, meta.Result.ExitCode)
+		assert.NotZero(t, meta.Result.Error)
+		assert.False(t, meta.Result.Finished.Before(meta.Result.Started))
+	})
+}
+
+func TestRun_retry(t *testing.T) {
+	// The first task counts its runs, the second fails the first time
+	// it runs, so the job should resume at the second task.
+	stateDir := t.TempDir()
+	counter := filepath.Join(stateDir, "count.txt")
+	marker := filepath.Join(stateDir, "failed")
+
+	retries := 1
+	ws := spec.Workflow{
+		Tasks: spec.TaskSet{
+			{
+				Name:    "count",
+				Cmd:     []string{"sh", "-c", "echo run >> " + counter + " && mv data.txt count.txt"},
+				Inputs:  []spec.Input{{Name: "data.txt"}},
+				Outputs: []files.Path{"count.txt"},
+			},
+			{
+				Name:    "flaky",
+				Cmd:     []string{"sh", "-c", "if [ ! -e " + marker + " ]; then touch " + marker + "; exit 1; fi; mv count.txt flaky.txt"},
+				Inputs:  []spec.Input{{Name: "count.txt"}},
+				Outputs: []files.Path{"flaky.txt"},
+				Retries: &retries,
+			},
+		},
+	}
+
+	for _, isolate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("isolate=%v", isolate), func(t *testing.T) {
+			_ = os.Remove(counter)
+			_ = os.Remove(marker)
+
+			ws.IsolateTasks = isolate
+
+			workDir := files.Dir(t.TempDir())
+			outDir := workDir.SubDir("outputs")
+
+			wi, err := NewInstance(ws, WithWorkDir(workDir))
+			assert.NoError(t, err)
+
+			inDir := t.TempDir()
+			err = os.WriteFile(filepath.Join(inDir, "in.txt"), nil, 0644)
+			assert.NoError(t, err)
+
+			in, err := inputs.Local(files.Dir(inDir))
+			assert.NoError(t, err)
+
+			runner := &task.LocalRunner{WorkDir: workDir, Store: &files.Local{}}
+
+			err = Run(context.Background(), wi, runner, in, outDir, 1)
+			assert.NoError(t, err)
+
+			runs, err := os.ReadFile(counter)
+			assert.NoError(t, err)
+			assert.Equal(t, "run\n", string(runs))
+
+			outputs, err := filepath.Glob(filepath.Join(string(outDir), "*", "flaky.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(outputs))
+
+			// Every volume should have been cleaned up.
+			vols, err := filepath.Glob(filepath.Join(string(workDir), "volumes", "*"))
+			assert.NoError(t, err)
+			assert.Equal(t, 0, len(vols))
+		})
+	}
+}